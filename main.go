@@ -2,12 +2,21 @@ package main
 
 import (
 	"encoding/json"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/RentTheRunway/blanche/pkg/config"
 	"github.com/RentTheRunway/blanche/pkg/gh"
 	"github.com/RentTheRunway/blanche/pkg/handlers"
+	"github.com/RentTheRunway/blanche/pkg/poller"
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"github.com/RentTheRunway/blanche/pkg/store"
 	"github.com/gorilla/mux"
 )
 
@@ -18,13 +27,88 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	log.SetFlags(log.Lshortfile | log.LstdFlags)
 	gh.CreateGithubClient(os.Getenv("GITHUB_ACCESS_TOKEN"))
 
+	// Fail fast on a manifest that would never match, instead of at webhook time
+	if err := config.ReloadManifests(); err != nil {
+		log.Fatal(err)
+	}
+	watchManifests()
+	if err := config.LoadGenericWebhooks(); err != nil {
+		log.Fatal(err)
+	}
+	// The poller and the reconciler both read the registries, so they're loaded before either starts
+	if err := config.LoadRegistries(); err != nil {
+		log.Fatal(err)
+	}
+
+	storePath := os.Getenv("STORE_PATH")
+	if storePath == "" {
+		storePath = "blanche-store.json"
+	}
+	events, err := store.Open(storePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	events.Retention = getEnvDuration("STORE_RETENTION", store.DefaultRetention)
+
+	processor := queue.NewProcessor(events)
+	processor.MaxAttempts = getEnvInt("RETRY_MAX_ATTEMPTS", queue.DefaultMaxAttempts)
+	processor.BaseDelay = getEnvDuration("RETRY_BASE_DELAY", queue.DefaultBaseDelay)
+	processor.MaxDelay = getEnvDuration("RETRY_MAX_DELAY", queue.DefaultMaxDelay)
+	processor.EventSink = os.Getenv("CLOUDEVENTS_SINK")
+	if source := os.Getenv("CLOUDEVENTS_SOURCE"); source != "" {
+		processor.EventSource = source
+	}
+
+	jobs := queue.New(
+		getEnvInt("QUEUE_WORKERS", queue.DefaultWorkers),
+		getEnvInt("QUEUE_SIZE", queue.DefaultSize),
+		events,
+		processor.Process,
+	)
+	jobs.Start()
+	expvar.Publish("queue", expvar.Func(func() interface{} { return jobs.Stats() }))
+	if n := jobs.Replay(); n > 0 {
+		log.Printf("replayed %d unfinished jobs from %s", n, storePath)
+	}
+	jobs.RetryEvery(queue.RetryInterval)
+
+	if interval := getEnvDuration("POLL_INTERVAL", 0); interval > 0 {
+		log.Printf("polling registries every %s", interval)
+		poller.New(jobs).Every(interval)
+	}
+	if interval := getEnvDuration("RECONCILE_INTERVAL", 0); interval > 0 {
+		reconciler := poller.NewReconciler(jobs, events)
+		reconciler.DryRun = getEnvBool("RECONCILE_DRY_RUN", false)
+		reconciler.Registry = getEnvBool("RECONCILE_REGISTRY", false)
+		expvar.Publish("drift", expvar.Func(func() interface{} { return reconciler.Drift() }))
+		log.Printf("reconciling manifests every %s (dry run: %t)", interval, reconciler.DryRun)
+		reconciler.Every(interval)
+	}
+
 	r := mux.NewRouter()
-	r.HandleFunc("/webhook/{type}", handlers.DockerHandler)
+	r.HandleFunc("/webhook/{type}", handlers.DockerHandler(jobs))
+	r.HandleFunc("/webhook/{type}/{name}", handlers.DockerHandler(jobs))
+	r.HandleFunc("/queue", handlers.QueueHandler(jobs))
+	r.HandleFunc("/manifest/refresh", handlers.ManifestRefreshHandler()).Methods(http.MethodPost)
+	r.HandleFunc("/deadletters", handlers.DeadLettersHandler(events)).Methods(http.MethodGet)
+	r.HandleFunc("/deadletters/{id}", handlers.DeadLetterHandler(events)).Methods(http.MethodGet)
+	r.HandleFunc("/deadletters/{id}/redrive", handlers.RedriveHandler(jobs)).Methods(http.MethodPost)
+	r.Handle("/debug/vars", expvar.Handler())
 	r.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]string{"buildTime": BuildTime, "buildVersion": BuildVersion})
+		manifest := config.GetManifestStatus()
+		json.NewEncoder(w).Encode(map[string]string{
+			"buildTime":       BuildTime,
+			"buildVersion":    BuildVersion,
+			"manifestVersion": manifest.Version,
+			"manifestError":   manifest.Error,
+		})
 	})
 
 	port := os.Getenv("PORT")
@@ -35,3 +119,42 @@ func main() {
 	log.Println("now listening on", address)
 	log.Fatal(http.ListenAndServe(address, r))
 }
+
+// watchManifests reloads the manifest when its file changes, or when blanche receives a SIGHUP
+func watchManifests() {
+	if interval := getEnvDuration("MANIFEST_RELOAD_INTERVAL", config.DefaultReloadInterval); interval > 0 {
+		config.WatchManifests(interval, nil)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("received SIGHUP, reloading the manifest")
+			config.ReloadManifests()
+		}
+	}()
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
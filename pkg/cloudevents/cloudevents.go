@@ -0,0 +1,143 @@
+package cloudevents
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	SpecVersion = "1.0"
+	// ContentType is the content type of structured mode events
+	ContentType = "application/cloudevents+json"
+
+	// ImagePushed is the type of event blanche receives when a docker image tag is pushed
+	ImagePushed = "image.pushed"
+	// UpdateCommitted is the type of event blanche sends when it pushes a commit to a base branch
+	UpdateCommitted = "blanche.update.committed"
+	// PullRequestOpened is the type of event blanche sends when it opens a pull request
+	PullRequestOpened = "blanche.pullrequest.opened"
+)
+
+var ErrInvalidEvent = errors.New("Not a valid CloudEvent")
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Event is a CloudEvents 1.0 event in its structured JSON format
+// https://github.com/cloudevents/spec/blob/v1.0/spec.md
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            *time.Time      `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// ImagePushedData is the data of an ImagePushed event
+type ImagePushedData struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+// UpdateData is the data of the UpdateCommitted and PullRequestOpened events
+type UpdateData struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	ConfigRepo string `json:"config_repo"`
+	File       string `json:"file"`
+	BaseBranch string `json:"base_branch"`
+	URL        string `json:"url"`
+}
+
+// New creates an event with a random ID, encoding data as JSON
+func New(eventType, source, subject string, data interface{}) (Event, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Event{}, err
+	}
+	now := time.Now().UTC()
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              hex.EncodeToString(id),
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            &now,
+		DataContentType: "application/json",
+		Data:            b,
+	}, nil
+}
+
+// FromRequest reads an event sent over HTTP in either structured or binary mode
+func FromRequest(r *http.Request, body []byte) (Event, error) {
+	var e Event
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == ContentType {
+		if err := json.Unmarshal(body, &e); err != nil {
+			return e, err
+		}
+	} else {
+		// In binary mode, the attributes are headers and the body is the data
+		e = Event{
+			SpecVersion:     r.Header.Get("ce-specversion"),
+			ID:              r.Header.Get("ce-id"),
+			Source:          r.Header.Get("ce-source"),
+			Type:            r.Header.Get("ce-type"),
+			Subject:         r.Header.Get("ce-subject"),
+			DataContentType: r.Header.Get("Content-Type"),
+			DataSchema:      r.Header.Get("ce-dataschema"),
+			Data:            body,
+		}
+		if t := r.Header.Get("ce-time"); t != "" {
+			parsed, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return e, err
+			}
+			e.Time = &parsed
+		}
+	}
+
+	if e.SpecVersion != SpecVersion || e.ID == "" || e.Source == "" || e.Type == "" {
+		return e, ErrInvalidEvent
+	}
+	return e, nil
+}
+
+// IsType returns true if the event's type is eventType, optionally with a reverse-DNS
+// prefix, like `com.example.image.pushed`
+func (e Event) IsType(eventType string) bool {
+	return e.Type == eventType || strings.HasSuffix(e.Type, "."+eventType)
+}
+
+// Send posts the event to sink in structured mode
+func Send(sink string, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(sink, ContentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("sending %s event returned %s", e.Type, resp.Status)
+	}
+	return nil
+}
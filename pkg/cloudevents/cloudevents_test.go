@@ -0,0 +1,114 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	data := `{"repository":"o/r","tag":"v1.2.0"}`
+
+	structured := httptest.NewRequest(http.MethodPost, "/webhook/cloudevents", nil)
+	structured.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	structuredBody := `{
+	  "specversion": "1.0",
+	  "id": "A234-1234-1234",
+	  "source": "https://ci.example.com/o/r",
+	  "type": "com.example.image.pushed",
+	  "datacontenttype": "application/json",
+	  "data": ` + data + `
+	}`
+
+	binary := httptest.NewRequest(http.MethodPost, "/webhook/cloudevents", nil)
+	binary.Header.Set("Content-Type", "application/json")
+	binary.Header.Set("ce-specversion", "1.0")
+	binary.Header.Set("ce-id", "A234-1234-1234")
+	binary.Header.Set("ce-source", "https://ci.example.com/o/r")
+	binary.Header.Set("ce-type", "com.example.image.pushed")
+
+	tests := []struct {
+		r    *http.Request
+		body string
+	}{
+		{structured, structuredBody},
+		{binary, data},
+	}
+	for _, test := range tests {
+		e, err := FromRequest(test.r, []byte(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.ID != "A234-1234-1234" || e.Source != "https://ci.example.com/o/r" || !e.IsType(ImagePushed) {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		var got ImagePushedData
+		if err := json.Unmarshal(e.Data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if expected := (ImagePushedData{Repository: "o/r", Tag: "v1.2.0"}); got != expected {
+			t.Errorf("expected: %+v, got: %+v", expected, got)
+		}
+	}
+
+	// Binary mode without the required headers
+	missing := httptest.NewRequest(http.MethodPost, "/webhook/cloudevents", nil)
+	missing.Header.Set("ce-specversion", "1.0")
+	if _, err := FromRequest(missing, []byte(data)); err != ErrInvalidEvent {
+		t.Errorf("expected error: %s, got: %v", ErrInvalidEvent, err)
+	}
+}
+
+func TestEvent_IsType(t *testing.T) {
+	tests := []struct {
+		eventType string
+		expected  bool
+	}{
+		{"image.pushed", true},
+		{"com.example.image.pushed", true},
+		{"com.example.notimage.pushed", false},
+		{"image.pushed.v2", false},
+	}
+	for _, test := range tests {
+		if got := (Event{Type: test.eventType}).IsType(ImagePushed); got != test.expected {
+			t.Errorf("%s | expected: %t, got: %t", test.eventType, test.expected, got)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	data := UpdateData{Repository: "o/r", Tag: "v1", ConfigRepo: "o/configs", File: "values.yaml", BaseBranch: "master", URL: "https://github.com/o/configs/pull/1"}
+	e, err := New(PullRequestOpened, "blanche", "o/r:v1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != ContentType {
+			t.Errorf("expected content type: %s, got: %s", ContentType, ct)
+		}
+		var got Event
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		var gotData UpdateData
+		json.Unmarshal(got.Data, &gotData)
+		if got.Type != PullRequestOpened || got.ID != e.ID || got.SpecVersion != "1.0" || !reflect.DeepEqual(gotData, data) {
+			t.Errorf("unexpected event: %+v", got)
+		}
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	if err := Send(server.URL, e); err != nil {
+		t.Error(err)
+	}
+	if err := Send(server.URL+"/fail", e); err == nil {
+		t.Error("should have returned an error, but got nil")
+	}
+}
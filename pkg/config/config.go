@@ -2,20 +2,17 @@ package config
 
 import (
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 
 	"github.com/RentTheRunway/blanche/pkg/gh"
 	"golang.org/x/mod/semver"
-	"gopkg.in/yaml.v2"
 )
 
 var ErrTagNotValid = errors.New("Tag is not valid semver")
 
-var manifests ManifestConfigs
-
 type ManifestConfigs []ManifestConfig
 
 type ManifestConfig struct {
@@ -28,54 +25,123 @@ type ManifestEntry struct {
 	File        string `yaml:"file"`
 	BaseBranch  string `yaml:"base_branch"`
 	PullRequest bool   `yaml:"pull_request"`
+	// Source is the file and line the entry is defined at
+	Source string `yaml:"-"`
 }
 
-func GetManifest(dockerRepo string) *ManifestConfig {
-	if manifests == nil {
-		if err := manifests.load(getEnvDefault("MANIFEST_PATH", "manifest.yaml")); err != nil {
-			log.Println(err)
-			return nil
-		}
+// GetManifest returns the docker repo's manifest config. When the image is also known by other names,
+// like with its registry's host in front of it, the manifest configs of every name are merged.
+func GetManifest(dockerRepo string, aliases ...string) *ManifestConfig {
+	mcs := GetManifests()
+	if mcs == nil {
+		return nil
+	}
+	m, overlaps := mcs.resolve(append([]string{dockerRepo}, aliases...)...)
+	warnOverlaps(dockerRepo, overlaps)
+	return m
+}
+
+// GetManifests returns every docker repo in the manifest. It's loaded the first time
+// it's needed, and after that only when it's reloaded, see ReloadManifests.
+func GetManifests() ManifestConfigs {
+	manifestsMu.RLock()
+	loaded := manifestsLoaded
+	mcs := manifests
+	manifestsMu.RUnlock()
+	if !loaded {
+		ReloadManifests()
+		manifestsMu.RLock()
+		mcs = manifests
+		manifestsMu.RUnlock()
 	}
-	return manifests.getManifest(dockerRepo)
+	return mcs
 }
 
+// getManifest returns the docker repo's manifest config, with the variables from its docker_repo
+// rendered in its entries. The entries of every docker_repo that matches it are merged, see merge.
 func (mcs *ManifestConfigs) getManifest(dockerRepo string) *ManifestConfig {
+	m, _ := mcs.resolve(dockerRepo)
+	return m
+}
+
+// resolve returns the manifest config for the first of the names like getManifest, merged with the
+// manifest configs of the other names, along with a warning for each entry that was dropped because
+// another entry updates the same file
+func (mcs *ManifestConfigs) resolve(names ...string) (*ManifestConfig, []string) {
+	var matched []*ManifestConfig
 	for _, m := range *mcs {
-		if m.DockerRepo == dockerRepo {
-			return &m
+		for _, name := range names {
+			if _, ok := m.match(name); ok {
+				matched = append(matched, m.Resolve(name))
+				// A docker_repo is only used once, even when more than one of the names match it
+				break
+			}
 		}
 	}
-	return nil
+	if len(matched) == 0 {
+		return nil, nil
+	}
+	return merge(names[0], matched)
 }
 
-func (mcs *ManifestConfigs) load(filename string) error {
-	yamlFile, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
+// Resolved returns a manifest config for each docker repo that isn't a pattern,
+// with the entries of every docker_repo that matches it merged, see GetManifest
+func (mcs ManifestConfigs) Resolved() ManifestConfigs {
+	var resolved ManifestConfigs
+	seen := map[string]bool{}
+	for _, m := range mcs {
+		if m.IsPattern() || seen[m.DockerRepo] {
+			continue
+		}
+		seen[m.DockerRepo] = true
+		resolved = append(resolved, *mcs.getManifest(m.DockerRepo))
+	}
+	return resolved
+}
+
+// merge unions the entries of the manifest configs. When more than one entry updates the same file,
+// only the first one is kept, and a warning is returned for each of the others.
+func merge(dockerRepo string, matched []*ManifestConfig) (*ManifestConfig, []string) {
+	merged := ManifestConfig{DockerRepo: dockerRepo, Manifests: []ManifestEntry{}}
+	var overlaps []string
+	first := map[ManifestEntry]ManifestEntry{}
+	for _, m := range matched {
+		for _, entry := range m.Manifests {
+			target := ManifestEntry{ConfigRepo: entry.ConfigRepo, File: entry.File, BaseBranch: entry.BaseBranch}
+			if f, ok := first[target]; ok {
+				overlaps = append(overlaps, fmt.Sprintf("manifests defined at %s and %s both update %s/%s on %s, only the one at %s is used",
+					f.Source, entry.Source, entry.ConfigRepo, entry.File, entry.BaseBranch, f.Source))
+				continue
+			}
+			first[target] = entry
+			merged.Manifests = append(merged.Manifests, entry)
+		}
 	}
-	return yaml.Unmarshal(yamlFile, &mcs)
+	return &merged, overlaps
+}
+
+// Resolve returns the manifest config for a docker repo that matches it, with its entries rendered
+func (m ManifestConfig) Resolve(dockerRepo string) *ManifestConfig {
+	vars, _ := m.match(dockerRepo)
+	resolved := ManifestConfig{DockerRepo: dockerRepo, Manifests: []ManifestEntry{}}
+	for _, entry := range m.Manifests {
+		rendered, err := entry.render(vars)
+		if err != nil {
+			log.Printf("%s | skipping manifest defined at %s: %s", dockerRepo, entry.Source, err)
+			continue
+		}
+		resolved.Manifests = append(resolved.Manifests, rendered)
+	}
+	return &resolved
 }
 
 func (m *ManifestConfig) GenerateGitUpdates(name, tag string) error {
-	// Only update tags that match semver
-	if !(semver.IsValid(tag)) {
-		log.Printf("tag was not semver, skipping: %s", tag)
-		return ErrTagNotValid
+	if err := ValidateTag(tag); err != nil {
+		return err
 	}
 
 	for _, mc := range m.Manifests {
-		repoOwner, repoName := parseRepo(mc.ConfigRepo)
-		if err := gh.NewGitUpdates(
-			repoOwner,
-			repoName,
-			mc.File,
-			mc.BaseBranch,
-			name,
-			tag,
-			mc.PullRequest,
-			true, // TODO: configurable via Manifest
-		).CreateUpdates(); err != nil {
+		if _, err := mc.CreateGitUpdate(name, tag); err != nil {
 			log.Printf("%s:%s | %s\n%+v", name, tag, err, mc)
 		}
 	}
@@ -83,6 +149,51 @@ func (m *ManifestConfig) GenerateGitUpdates(name, tag string) error {
 	return nil
 }
 
+// ValidateTag returns ErrTagNotValid if the tag shouldn't be used to update manifests
+func ValidateTag(tag string) error {
+	// Only update tags that match semver
+	if !(semver.IsValid(tag)) {
+		log.Printf("tag was not semver, skipping: %s", tag)
+		return ErrTagNotValid
+	}
+	return nil
+}
+
+// CreateGitUpdate pushes the new tag to the manifest file.
+// It returns the URL of the PR or commit that was created.
+func (mc ManifestEntry) CreateGitUpdate(name, tag string) (string, error) {
+	repoOwner, repoName := parseRepo(mc.ConfigRepo)
+	return gh.NewGitUpdates(
+		repoOwner,
+		repoName,
+		mc.File,
+		mc.BaseBranch,
+		name,
+		tag,
+		mc.PullRequest,
+		true, // TODO: configurable via Manifest
+	).CreateUpdates()
+}
+
+// CurrentTag returns the tag that is currently in the manifest file on the base branch
+func (mc ManifestEntry) CurrentTag() (string, error) {
+	repoOwner, repoName := parseRepo(mc.ConfigRepo)
+	return gh.CurrentTag(repoOwner, repoName, mc.File, mc.BaseBranch)
+}
+
+// ReleasePending returns true if a pull request updating the manifest file to the tag was already
+// opened, or at least its branch was pushed, so the file will have the tag once it's merged
+func (mc ManifestEntry) ReleasePending(name, tag string) (bool, error) {
+	repoOwner, repoName := parseRepo(mc.ConfigRepo)
+	return gh.ReleaseBranchExists(repoOwner, repoName, mc.BaseBranch, name, tag)
+}
+
+// Check returns an error if the config repo, base branch or file don't exist in GitHub
+func (mc ManifestEntry) Check() error {
+	repoOwner, repoName := parseRepo(mc.ConfigRepo)
+	return gh.CheckManifest(repoOwner, repoName, mc.File, mc.BaseBranch)
+}
+
 func parseRepo(repo string) (owner string, name string) {
 	split := strings.Split(repo, "/")
 	switch len(split) {
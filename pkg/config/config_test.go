@@ -6,13 +6,12 @@ import (
 	"testing"
 )
 
-func TestLoad(t *testing.T) {
-	var manifests ManifestConfigs
-	if err := manifests.load("manifest-test.yaml"); err != nil {
+func TestReadManifests(t *testing.T) {
+	if _, _, _, err := readManifests("manifest-test.yaml"); err != nil {
 		t.Error(err)
 	}
 
-	if err := manifests.load("manifest-no.yaml"); err == nil {
+	if _, _, _, err := readManifests("manifest-no.yaml"); err == nil {
 		t.Error("should have returned an error, but got nil")
 	}
 }
@@ -27,7 +26,7 @@ func TestGetManifest(t *testing.T) {
 		{"celfring/guestbook", &ManifestConfig{
 			DockerRepo: "celfring/guestbook",
 			Manifests: []ManifestEntry{
-				{File: "charts/guestbook/values.yaml", ConfigRepo: "caitlin615/argocd-demo", BaseBranch: "master", PullRequest: false},
+				{File: "charts/guestbook/values.yaml", ConfigRepo: "caitlin615/argocd-demo", BaseBranch: "master", PullRequest: false, Source: "manifest-test.yaml:4"},
 			},
 		}},
 		{"celfring/no-entry", nil},
@@ -42,12 +41,80 @@ func TestGetManifest(t *testing.T) {
 
 	// Test GetManifest failure if manifest config file can't be found
 	os.Setenv("MANIFEST_PATH", "manifest-no.yaml")
-	manifests = nil
+	resetManifests()
 	m2 := GetManifest("celfring/guestbook")
 	if m2 != nil {
 		t.Errorf("expected nil manifest, got %v", m2)
 	}
+	os.Setenv("MANIFEST_PATH", "manifest-test.yaml")
+	resetManifests()
+}
 
+func TestGetManifestMerges(t *testing.T) {
+	entry := func(file, source string, pr bool) ManifestEntry {
+		return ManifestEntry{ConfigRepo: "caitlin615/argocd-demo", File: file, BaseBranch: "master", PullRequest: pr, Source: source}
+	}
+	mcs := ManifestConfigs{
+		{DockerRepo: "celfring/guestbook", Manifests: []ManifestEntry{
+			entry("charts/guestbook/values-staging.yaml", "team-a.yaml:3", false),
+			entry("charts/guestbook/values.yaml", "team-a.yaml:6", false),
+		}},
+		{DockerRepo: "celfring/*", Manifests: []ManifestEntry{
+			entry("charts/{{.name}}/values-demo.yaml", "shared.yaml:3", false),
+		}},
+		{DockerRepo: "celfring/guestbook", Manifests: []ManifestEntry{
+			// The same entry is only kept once, and the first entry for a file wins
+			entry("charts/guestbook/values-staging.yaml", "team-b.yaml:3", false),
+			entry("charts/guestbook/values.yaml", "team-b.yaml:6", true),
+			entry("charts/guestbook/values-production.yaml", "team-b.yaml:9", true),
+		}},
+		{DockerRepo: "celfring/k8s-*", Manifests: []ManifestEntry{
+			entry("charts/k8s-demo/values.yaml", "shared.yaml:9", false),
+		}},
+		{DockerRepo: "/^celfring/k8s-.*$/", Manifests: []ManifestEntry{
+			entry("charts/k8s-demo/values.yaml", "shared.yaml:15", false),
+			entry("charts/{{.name}}/values-demo.yaml", "shared.yaml:18", false),
+		}},
+	}
+
+	tests := []struct {
+		value    string
+		expected *ManifestConfig
+		overlaps []string
+	}{
+		// Patterns are merged along with exact matches
+		{"celfring/guestbook", &ManifestConfig{DockerRepo: "celfring/guestbook", Manifests: []ManifestEntry{
+			entry("charts/guestbook/values-staging.yaml", "team-a.yaml:3", false),
+			entry("charts/guestbook/values.yaml", "team-a.yaml:6", false),
+			entry("charts/guestbook/values-demo.yaml", "shared.yaml:3", false),
+			entry("charts/guestbook/values-production.yaml", "team-b.yaml:9", true),
+		}}, []string{
+			"manifests defined at team-a.yaml:3 and team-b.yaml:3 both update caitlin615/argocd-demo/charts/guestbook/values-staging.yaml on master, only the one at team-a.yaml:3 is used",
+			"manifests defined at team-a.yaml:6 and team-b.yaml:6 both update caitlin615/argocd-demo/charts/guestbook/values.yaml on master, only the one at team-a.yaml:6 is used",
+		}},
+		{"celfring/k8s-demo", &ManifestConfig{DockerRepo: "celfring/k8s-demo", Manifests: []ManifestEntry{
+			entry("charts/k8s-demo/values-demo.yaml", "shared.yaml:3", false),
+			entry("charts/k8s-demo/values.yaml", "shared.yaml:9", false),
+		}}, []string{
+			"manifests defined at shared.yaml:9 and shared.yaml:15 both update caitlin615/argocd-demo/charts/k8s-demo/values.yaml on master, only the one at shared.yaml:9 is used",
+			"manifests defined at shared.yaml:3 and shared.yaml:18 both update caitlin615/argocd-demo/charts/k8s-demo/values-demo.yaml on master, only the one at shared.yaml:3 is used",
+		}},
+		{"other/guestbook", nil, nil},
+	}
+	for _, test := range tests {
+		m, overlaps := mcs.resolve(test.value)
+		if !reflect.DeepEqual(m, test.expected) {
+			t.Errorf("expected: %+v got: %+v", test.expected, m)
+		}
+		if !reflect.DeepEqual(overlaps, test.overlaps) {
+			t.Errorf("expected overlaps: %q got: %q", test.overlaps, overlaps)
+		}
+	}
+
+	resolved := mcs.Resolved()
+	if len(resolved) != 1 || resolved[0].DockerRepo != "celfring/guestbook" || len(resolved[0].Manifests) != 4 {
+		t.Errorf("expected celfring/guestbook with 4 manifests, got: %+v", resolved)
+	}
 }
 
 func TestGetEnvDefault(t *testing.T) {
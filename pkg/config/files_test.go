@@ -0,0 +1,119 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	teamAManifest = `- docker_repo: celfring/guestbook
+  manifests:
+    - file: charts/guestbook/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+`
+	teamBManifest = `- docker_repo: celfring/k8s-demo
+  manifests:
+    - file: charts/k8s-demo/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+      pull_request: true
+`
+)
+
+func manifestDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "blanche-manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestManifestFiles(t *testing.T) {
+	dir := manifestDir(t, map[string]string{
+		"team-b.yml":            teamBManifest,
+		"team-a.yaml":           teamAManifest,
+		"README.md":             "not a manifest",
+		"nested/team-c.yaml":    teamAManifest,
+		"empty/not-a-manifest":  "",
+		"single/manifest.yaml":  teamAManifest,
+		"single/ignored/x.yaml": teamAManifest,
+	})
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{dir, []string{"team-a.yaml", "team-b.yml"}},
+		{filepath.Join(dir, "*/*.yaml"), []string{"nested/team-c.yaml", "single/manifest.yaml"}},
+		{filepath.Join(dir, "single/manifest.yaml"), []string{"single/manifest.yaml"}},
+	}
+	for _, test := range tests {
+		files, err := manifestFiles(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range files {
+			got = append(got, strings.TrimPrefix(f, dir+"/"))
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s | expected: %v, got: %v", test.path, test.expected, got)
+		}
+	}
+
+	for _, path := range []string{filepath.Join(dir, "empty"), filepath.Join(dir, "*.json"), filepath.Join(dir, "missing.yaml")} {
+		if _, err := manifestFiles(path); err == nil {
+			t.Errorf("%s | should have returned an error, but got nil", path)
+		}
+	}
+}
+
+func TestReadManifestsDirectory(t *testing.T) {
+	dir := manifestDir(t, map[string]string{"team-a.yaml": teamAManifest, "team-b.yaml": teamBManifest})
+	mcs, version, fp, err := readManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ManifestConfigs{
+		{DockerRepo: "celfring/guestbook", Manifests: []ManifestEntry{
+			{File: "charts/guestbook/values.yaml", ConfigRepo: "caitlin615/argocd-demo", BaseBranch: "master", Source: filepath.Join(dir, "team-a.yaml") + ":3"},
+		}},
+		{DockerRepo: "celfring/k8s-demo", Manifests: []ManifestEntry{
+			{File: "charts/k8s-demo/values.yaml", ConfigRepo: "caitlin615/argocd-demo", BaseBranch: "master", PullRequest: true, Source: filepath.Join(dir, "team-b.yaml") + ":3"},
+		}},
+	}
+	if !reflect.DeepEqual(mcs, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, mcs)
+	}
+	if version == "" || fp == "" {
+		t.Errorf("expected a version and fingerprint, got: %q %q", version, fp)
+	}
+
+	// Adding a file changes the fingerprint, and a docker repo can be defined in more than one file
+	if err := ioutil.WriteFile(filepath.Join(dir, "team-c.yaml"), []byte(teamAManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint(dir) == fp {
+		t.Error("expected the fingerprint to change when a file is added")
+	}
+	mcs, _, _, err = readManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mcs) != 3 {
+		t.Errorf("expected: 3 docker repos, got: %d", len(mcs))
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// IsPattern returns true if the docker repo is a glob, like `celfring/*`,
+// or a regex between slashes, like `/^celfring/(?P<app>[^/]+)$/`
+func (m ManifestConfig) IsPattern() bool {
+	return m.isRegex() || strings.ContainsAny(m.DockerRepo, "*?[")
+}
+
+func (m ManifestConfig) isRegex() bool {
+	return len(m.DockerRepo) > 2 && strings.HasPrefix(m.DockerRepo, "/") && strings.HasSuffix(m.DockerRepo, "/")
+}
+
+func (m ManifestConfig) regex() (*regexp.Regexp, error) {
+	return regexp.Compile(m.DockerRepo[1 : len(m.DockerRepo)-1])
+}
+
+// match returns the template variables for the docker repo if it matches: `repo` is the
+// whole docker repo, `name` is its last path element, and a regex's named captures are added to them
+func (m ManifestConfig) match(dockerRepo string) (map[string]string, bool) {
+	vars := map[string]string{"repo": dockerRepo, "name": path.Base(dockerRepo)}
+	switch {
+	case m.isRegex():
+		re, err := m.regex()
+		if err != nil {
+			return nil, false
+		}
+		captures := re.FindStringSubmatch(dockerRepo)
+		if captures == nil {
+			return nil, false
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				vars[name] = captures[i]
+			}
+		}
+		return vars, true
+	case m.IsPattern():
+		ok, _ := path.Match(m.DockerRepo, dockerRepo)
+		return vars, ok
+	default:
+		return vars, m.DockerRepo == dockerRepo
+	}
+}
+
+// templateVars returns the names of the variables the docker repo's templates can use
+func (m ManifestConfig) templateVars() map[string]string {
+	vars := map[string]string{"repo": "repo", "name": "name"}
+	if m.isRegex() {
+		if re, err := m.regex(); err == nil {
+			for _, name := range re.SubexpNames() {
+				if name != "" {
+					vars[name] = name
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// render returns the entry with the variables from the matching docker repo in its
+// config repo, file and base branch, like `charts/{{.name}}/values-prod.yaml`
+func (mc ManifestEntry) render(vars map[string]string) (ManifestEntry, error) {
+	var err error
+	for _, field := range []*string{&mc.ConfigRepo, &mc.File, &mc.BaseBranch} {
+		if *field, err = renderTemplate(*field, vars); err != nil {
+			return mc, err
+		}
+	}
+	return mc, nil
+}
+
+func renderTemplate(text string, vars map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
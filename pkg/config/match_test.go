@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestConfigs_getManifestPatterns(t *testing.T) {
+	entry := func(configRepo, file string) []ManifestEntry {
+		return []ManifestEntry{{ConfigRepo: configRepo, File: file, BaseBranch: "master"}}
+	}
+	mcs := ManifestConfigs{
+		{DockerRepo: `/^(?P<team>[a-z]+)/(?P<app>[a-z-]+)-service$/`, Manifests: entry("{{.team}}/configs", "charts/{{.app}}/values-prod.yaml")},
+		{DockerRepo: "celfring/*", Manifests: entry("caitlin615/argocd-demo", "charts/{{.name}}/values.yaml")},
+		{DockerRepo: "celfring/guestbook", Manifests: entry("caitlin615/guestbook", "values.yaml")},
+		{DockerRepo: "bad/*", Manifests: entry("o/r", "charts/{{.missing}}/values.yaml")},
+	}
+
+	tests := []struct {
+		dockerRepo string
+		expected   *ManifestConfig
+	}{
+		// Exact matches and patterns are merged, in the order they're defined
+		{"celfring/guestbook", &ManifestConfig{DockerRepo: "celfring/guestbook", Manifests: append(
+			entry("caitlin615/argocd-demo", "charts/guestbook/values.yaml"),
+			entry("caitlin615/guestbook", "values.yaml")...,
+		)}},
+		{"celfring/k8s-demo", &ManifestConfig{DockerRepo: "celfring/k8s-demo", Manifests: entry("caitlin615/argocd-demo", "charts/k8s-demo/values.yaml")}},
+		{"payments/ledger-service", &ManifestConfig{DockerRepo: "payments/ledger-service", Manifests: entry("payments/configs", "charts/ledger/values-prod.yaml")}},
+		{"celfring/nested/app", nil},
+		{"payments/ledger", nil},
+		// Entries that can't be rendered are skipped
+		{"bad/app", &ManifestConfig{DockerRepo: "bad/app", Manifests: []ManifestEntry{}}},
+	}
+	for _, test := range tests {
+		if got := mcs.getManifest(test.dockerRepo); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s | expected: %+v, got: %+v", test.dockerRepo, test.expected, got)
+		}
+	}
+}
+
+func TestManifestConfig_IsPattern(t *testing.T) {
+	tests := []struct {
+		dockerRepo string
+		expected   bool
+	}{
+		{"celfring/guestbook", false},
+		{"celfring/*", true},
+		{"celfring/app-?", true},
+		{"/^celfring/.*$/", true},
+		{"/", false},
+	}
+	for _, test := range tests {
+		if got := (ManifestConfig{DockerRepo: test.dockerRepo}).IsPattern(); got != test.expected {
+			t.Errorf("%s | expected: %t, got: %t", test.dockerRepo, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	registries     RegistryConfigs
+	registriesErr  error
+	registriesOnce sync.Once
+)
+
+type RegistryConfigs []RegistryConfig
+
+// RegistryConfig holds the credentials used to poll a docker registry.
+// The username and password can reference environment variables, like `${QUAY_PASSWORD}`,
+// so they don't need to be stored in the file.
+type RegistryConfig struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Insecure registries are polled over plain HTTP
+	Insecure bool `yaml:"insecure"`
+}
+
+// LoadRegistries reads REGISTRIES_PATH the first time it's called, and returns an error if it's invalid.
+// It's fine for the file not to exist, since every registry is then polled anonymously.
+func LoadRegistries() error {
+	registriesOnce.Do(func() {
+		path := getEnvDefault("REGISTRIES_PATH", "registries.yaml")
+		registries, registriesErr = readRegistries(path)
+		switch {
+		case os.IsNotExist(registriesErr):
+			log.Printf("%s doesn't exist, registries are polled anonymously", path)
+			registriesErr = nil
+		case registriesErr != nil:
+			log.Printf("failed to load %s, registries are polled anonymously: %s", path, registriesErr)
+		}
+	})
+	return registriesErr
+}
+
+// GetRegistry returns the config for the registry at host,
+// or nil if the registry should be polled anonymously
+func GetRegistry(host string) *RegistryConfig {
+	if err := LoadRegistries(); err != nil {
+		return nil
+	}
+	return registries.getRegistry(host)
+}
+
+func (rcs *RegistryConfigs) getRegistry(host string) *RegistryConfig {
+	for _, rc := range *rcs {
+		if rc.Host == host {
+			rc.Username = os.ExpandEnv(rc.Username)
+			rc.Password = os.ExpandEnv(rc.Password)
+			return &rc
+		}
+	}
+	return nil
+}
+
+func readRegistries(filename string) (RegistryConfigs, error) {
+	yamlFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var rcs RegistryConfigs
+	if err := yaml.UnmarshalStrict(yamlFile, &rcs); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return rcs, nil
+}
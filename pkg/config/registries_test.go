@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// resetRegistries makes the next call to GetRegistry load the file again
+func resetRegistries() {
+	registries = nil
+	registriesErr = nil
+	registriesOnce = sync.Once{}
+}
+
+func TestGetRegistry(t *testing.T) {
+	os.Setenv("REGISTRIES_PATH", "registries-test.yaml")
+	os.Setenv("TEST_QUAY_PASSWORD", "s3cret")
+	defer os.Unsetenv("TEST_QUAY_PASSWORD")
+	resetRegistries()
+
+	tests := []struct {
+		value    string
+		expected *RegistryConfig
+	}{
+		{"quay.io", &RegistryConfig{Host: "quay.io", Username: "robot+blanche", Password: "s3cret"}},
+		{"localhost:5000", &RegistryConfig{Host: "localhost:5000", Insecure: true}},
+		{"ghcr.io", nil},
+	}
+
+	for _, test := range tests {
+		rc := GetRegistry(test.value)
+		if !reflect.DeepEqual(rc, test.expected) {
+			t.Errorf("expected: %+v got: %+v", test.expected, rc)
+		}
+	}
+
+	// Every registry is polled anonymously if the config file can't be found
+	os.Setenv("REGISTRIES_PATH", "registries-no.yaml")
+	resetRegistries()
+	if err := LoadRegistries(); err != nil {
+		t.Errorf("expected no error, got: %s", err)
+	}
+	if rc := GetRegistry("quay.io"); rc != nil {
+		t.Errorf("expected nil registry, got %v", rc)
+	}
+	os.Setenv("REGISTRIES_PATH", "registries-test.yaml")
+	resetRegistries()
+}
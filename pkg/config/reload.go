@@ -0,0 +1,222 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/gh"
+)
+
+// DefaultReloadInterval is how often the manifest file is checked for changes
+const DefaultReloadInterval = 30 * time.Second
+
+var (
+	manifestsMu sync.RWMutex
+	manifests   ManifestConfigs
+	// manifestsLoaded is true once loading the manifest has been attempted,
+	// so a manifest that fails to load isn't read again on every webhook
+	manifestsLoaded  bool
+	manifestVersion  string
+	manifestLoadedAt time.Time
+	// manifestFingerprint identifies the files when they were last read, even if they failed to load
+	manifestFingerprint string
+	// manifestErr is the error from the last reload, if it failed
+	manifestErr error
+
+	overlapsMu sync.Mutex
+	// overlapsWarned holds the docker repos whose overlapping manifests have been logged,
+	// so they're only logged once for each version of the manifest
+	overlapsWarned = map[string]bool{}
+)
+
+// ManifestStatus describes the manifest that is currently in use
+type ManifestStatus struct {
+	Version  string    `json:"version"`
+	LoadedAt time.Time `json:"loaded_at,omitempty"`
+	// Error is set when the last reload failed, and the previous manifest is still in use
+	Error string `json:"error,omitempty"`
+}
+
+// ReloadManifests reads MANIFEST_PATH, from MANIFEST_REPO when it's set, and replaces the manifest
+// in use if it's valid. If it isn't, the previous manifest stays in use and the error is returned.
+func ReloadManifests() error {
+	path := getEnvDefault("MANIFEST_PATH", "manifest.yaml")
+	source := path
+	var mcs ManifestConfigs
+	var version, fp string
+	var err error
+	if repo, branch := ManifestRepo(); repo != "" {
+		source = fmt.Sprintf("%s@%s:%s", repo, branch, path)
+		mcs, version, fp, err = readGitManifests(repo, branch, path)
+	} else {
+		mcs, version, fp, err = readManifests(path)
+	}
+
+	manifestsMu.Lock()
+	defer manifestsMu.Unlock()
+	manifestsLoaded = true
+	manifestFingerprint = fp
+	manifestErr = err
+	if err != nil {
+		log.Printf("failed to load %s, keeping version %q: %s", source, manifestVersion, err)
+		return err
+	}
+	if version != manifestVersion {
+		log.Printf("loaded %s, version %s", source, version)
+		resetOverlaps()
+		// Overlaps for docker repos that only match patterns are logged the first time they're used instead
+		for _, m := range mcs {
+			if !m.IsPattern() {
+				_, overlaps := mcs.resolve(m.DockerRepo)
+				warnOverlaps(m.DockerRepo, overlaps)
+			}
+		}
+	}
+	manifests = mcs
+	manifestVersion = version
+	manifestLoadedAt = time.Now().UTC()
+	return nil
+}
+
+// warnOverlaps logs the docker repo's overlapping manifests, if they haven't been logged yet
+func warnOverlaps(dockerRepo string, overlaps []string) {
+	if len(overlaps) == 0 {
+		return
+	}
+	overlapsMu.Lock()
+	defer overlapsMu.Unlock()
+	if overlapsWarned[dockerRepo] {
+		return
+	}
+	overlapsWarned[dockerRepo] = true
+	for _, overlap := range overlaps {
+		log.Printf("%s | warning: %s", dockerRepo, overlap)
+	}
+}
+
+func resetOverlaps() {
+	overlapsMu.Lock()
+	defer overlapsMu.Unlock()
+	overlapsWarned = map[string]bool{}
+}
+
+// ManifestRepo returns the GitHub repo and branch the manifest is read from,
+// or "" if it's read from the local file system
+func ManifestRepo() (repo, branch string) {
+	return os.Getenv("MANIFEST_REPO"), getEnvDefault("MANIFEST_BRANCH", "master")
+}
+
+// GetManifestStatus returns the version of the manifest in use
+func GetManifestStatus() ManifestStatus {
+	manifestsMu.RLock()
+	defer manifestsMu.RUnlock()
+	status := ManifestStatus{Version: manifestVersion, LoadedAt: manifestLoadedAt}
+	if manifestErr != nil {
+		status.Error = manifestErr.Error()
+	}
+	return status
+}
+
+// WatchManifests reloads the manifest whenever one of its files is added, removed or modified,
+// checking on the given interval until stop is closed
+func WatchManifests(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if manifestChanged() {
+					ReloadManifests()
+				}
+			}
+		}
+	}()
+}
+
+// manifestChanged returns true if the manifest files changed since they were last read
+func manifestChanged() bool {
+	var fp string
+	if repo, branch := ManifestRepo(); repo != "" {
+		owner, name := parseRepo(repo)
+		fp, _ = gh.BranchSHA(owner, name, branch)
+	} else {
+		fp = fingerprint(getEnvDefault("MANIFEST_PATH", "manifest.yaml"))
+	}
+	manifestsMu.RLock()
+	defer manifestsMu.RUnlock()
+	// Files that failed to load are only read again once they change
+	return fp != "" && fp != manifestFingerprint
+}
+
+// readManifests reads and merges every manifest file at path
+func readManifests(path string) (mcs ManifestConfigs, version string, fp string, err error) {
+	fp = fingerprint(path)
+	names, err := manifestFiles(path)
+	if err != nil {
+		return nil, "", fp, err
+	}
+	var files []gh.File
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, "", fp, err
+		}
+		files = append(files, gh.File{Path: name, Data: data})
+	}
+	mcs, version, err = parseManifestFiles(files)
+	return mcs, version, fp, err
+}
+
+// readGitManifests reads and merges every manifest file at path in the repo, at the head of branch.
+// The fingerprint is the SHA of the commit they were read at.
+func readGitManifests(repo, branch, path string) (mcs ManifestConfigs, version string, fp string, err error) {
+	files, sha, err := gitManifestFiles(repo, branch, path)
+	if err != nil {
+		return nil, "", sha, err
+	}
+	mcs, version, err = parseManifestFiles(files)
+	return mcs, version, sha, err
+}
+
+// gitManifestFiles reads every manifest file at path in the repo, at the commit at the head of branch,
+// and returns them along with the SHA of that commit
+func gitManifestFiles(repo, branch, path string) ([]gh.File, string, error) {
+	owner, name := parseRepo(repo)
+	sha, err := gh.BranchSHA(owner, name, branch)
+	if err != nil {
+		return nil, "", err
+	}
+	files, err := gh.GetFiles(owner, name, sha, path)
+	if err != nil {
+		return nil, sha, err
+	}
+	for i := range files {
+		files[i].Path = fmt.Sprintf("%s@%s:%s", repo, branch, files[i].Path)
+	}
+	return files, sha, nil
+}
+
+// parseManifestFiles merges the manifest files. The version is a hash of their contents.
+func parseManifestFiles(files []gh.File) (ManifestConfigs, string, error) {
+	p := newManifestParser()
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\n", f.Path)
+		h.Write(f.Data)
+		p.parseFile(f.Path, f.Data)
+	}
+	mcs, err := p.result()
+	if err != nil {
+		return nil, "", err
+	}
+	return mcs, hex.EncodeToString(h.Sum(nil))[:12], nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resetManifests() {
+	manifestsMu.Lock()
+	defer manifestsMu.Unlock()
+	manifests = nil
+	manifestsLoaded = false
+	manifestVersion = ""
+	manifestLoadedAt = time.Time{}
+	manifestFingerprint = ""
+	manifestErr = nil
+}
+
+const (
+	validManifest = `
+- docker_repo: celfring/guestbook
+  manifests:
+    - file: charts/guestbook/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+`
+	updatedManifest = validManifest + `
+- docker_repo: celfring/k8s-demo
+  manifests:
+    - file: charts/k8s-demo/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+`
+	invalidManifest = `
+- docker_repo: celfring/guestbook
+  manifests:
+    - file: charts/guestbook/values.yaml
+      config_repo: argocd-demo
+`
+)
+
+// writeManifest writes the manifest with a modification time in the future,
+// so every write is seen as a change even within the file system's mtime resolution
+func writeManifest(t *testing.T, path, contents string, age int) {
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Duration(age) * time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tempManifest(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "blanche-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "manifest.yaml")
+	os.Setenv("MANIFEST_PATH", path)
+	resetManifests()
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		os.Setenv("MANIFEST_PATH", "manifest-test.yaml")
+		resetManifests()
+	})
+	return path
+}
+
+func TestReloadManifests(t *testing.T) {
+	path := tempManifest(t)
+	writeManifest(t, path, validManifest, 1)
+	if err := ReloadManifests(); err != nil {
+		t.Fatal(err)
+	}
+	first := GetManifestStatus()
+	if first.Version == "" || first.Error != "" || first.LoadedAt.IsZero() {
+		t.Errorf("unexpected status: %+v", first)
+	}
+
+	// An invalid manifest isn't used, and the error is reported
+	writeManifest(t, path, invalidManifest, 2)
+	if err := ReloadManifests(); err == nil {
+		t.Error("should have returned an error, but got nil")
+	}
+	status := GetManifestStatus()
+	if status.Version != first.Version || !strings.Contains(status.Error, "config_repo must be owner/name") {
+		t.Errorf("expected version %s to be kept with an error, got: %+v", first.Version, status)
+	}
+	if GetManifest("celfring/guestbook") == nil {
+		t.Error("expected the previous manifest to still be in use")
+	}
+
+	writeManifest(t, path, updatedManifest, 3)
+	if err := ReloadManifests(); err != nil {
+		t.Fatal(err)
+	}
+	if status := GetManifestStatus(); status.Version == first.Version || status.Error != "" {
+		t.Errorf("expected a new version without an error, got: %+v", status)
+	}
+	if GetManifest("celfring/k8s-demo") == nil {
+		t.Error("expected the updated manifest to be in use")
+	}
+}
+
+func TestGetManifestsLoadsOnce(t *testing.T) {
+	path := tempManifest(t)
+	if mcs := GetManifests(); mcs != nil {
+		t.Errorf("expected no manifests, got: %+v", mcs)
+	}
+
+	// A missing file isn't read again on every call
+	writeManifest(t, path, validManifest, 1)
+	if mcs := GetManifests(); mcs != nil {
+		t.Errorf("expected no manifests until reloaded, got: %+v", mcs)
+	}
+	if status := GetManifestStatus(); status.Error == "" {
+		t.Errorf("expected the load error to be reported, got: %+v", status)
+	}
+}
+
+func TestWatchManifests(t *testing.T) {
+	path := tempManifest(t)
+	writeManifest(t, path, validManifest, 1)
+	if err := ReloadManifests(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	WatchManifests(time.Millisecond, stop)
+
+	writeManifest(t, path, updatedManifest, 2)
+	for i := 0; GetManifest("celfring/k8s-demo") == nil; i++ {
+		if i > 1000 {
+			t.Fatal("expected the modified manifest to be reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWarnOverlaps(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	resetOverlaps()
+
+	overlaps := []string{"manifests defined at a.yaml:3 and b.yaml:3 both update o/r/values.yaml on master, only the one at a.yaml:3 is used"}
+	warnOverlaps("celfring/guestbook", overlaps)
+	warnOverlaps("celfring/guestbook", overlaps)
+	if n := strings.Count(logs.String(), "warning:"); n != 1 {
+		t.Errorf("expected the overlap to be logged once, got: %q", logs.String())
+	}
+
+	// Each version of the manifest logs its overlaps again
+	resetOverlaps()
+	warnOverlaps("celfring/guestbook", overlaps)
+	if n := strings.Count(logs.String(), "warning:"); n != 2 {
+		t.Errorf("expected the overlap to be logged again, got: %q", logs.String())
+	}
+}
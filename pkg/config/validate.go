@@ -0,0 +1,312 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RentTheRunway/blanche/pkg/gh"
+	"gopkg.in/yaml.v3"
+)
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// ValidationError is a problem with the manifest, and where in the file it is
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+}
+
+// ValidationErrors is every problem found in the manifest
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// manifestEntryNode is a ManifestEntry, along with the node it was parsed from
+type manifestEntryNode struct {
+	DockerRepo string
+	Entry      ManifestEntry
+	file       string
+	node       *yaml.Node
+}
+
+// manifestParser strictly parses manifest files, collecting every problem it finds
+type manifestParser struct {
+	// file is the file being parsed
+	file    string
+	errs    ValidationErrors
+	entries []manifestEntryNode
+	mcs     ManifestConfigs
+}
+
+func newManifestParser() *manifestParser {
+	return &manifestParser{}
+}
+
+// ValidateManifest strictly parses the manifest files at path, and returns ValidationErrors for
+// unknown or missing fields, config repos that aren't `owner/name`, and invalid patterns or templates.
+// With checkGitHub, it also checks that each entry's repo, branch and file exist in GitHub.
+func ValidateManifest(path string, checkGitHub bool) error {
+	names, err := manifestFiles(path)
+	if err != nil {
+		return err
+	}
+	var files []gh.File
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files = append(files, gh.File{Path: name, Data: data})
+	}
+	return validateFiles(files, checkGitHub)
+}
+
+// ValidateGitManifest validates the manifest files at path in the GitHub repo, at the head of branch,
+// the same way they're read when MANIFEST_REPO is set
+func ValidateGitManifest(repo, branch, path string, checkGitHub bool) error {
+	files, _, err := gitManifestFiles(repo, branch, path)
+	if err != nil {
+		return err
+	}
+	return validateFiles(files, checkGitHub)
+}
+
+func validateFiles(files []gh.File, checkGitHub bool) error {
+	p := newManifestParser()
+	for _, f := range files {
+		p.parseFile(f.Path, f.Data)
+	}
+	if _, err := p.result(); err != nil {
+		return err
+	}
+	if !checkGitHub {
+		return nil
+	}
+	for _, e := range p.entries {
+		// Entries for patterns can only be checked once they match a docker repo
+		mc := ManifestConfig{DockerRepo: e.DockerRepo}
+		if mc.IsPattern() {
+			continue
+		}
+		vars, _ := mc.match(e.DockerRepo)
+		entry, err := e.Entry.render(vars)
+		if err == nil {
+			err = entry.Check()
+		}
+		if err != nil {
+			p.file = e.file
+			p.errorf(e.node, "%s: %s", e.DockerRepo, err)
+		}
+	}
+	return p.errOrNil()
+}
+
+// parseManifest strictly parses a single manifest file. Its error is always ValidationErrors.
+func parseManifest(filename string, data []byte) (ManifestConfigs, error) {
+	p := newManifestParser()
+	p.parseFile(filename, data)
+	return p.result()
+}
+
+// result returns the docker repos from every file that was parsed, or every problem that was found
+func (p *manifestParser) result() (ManifestConfigs, error) {
+	if err := p.errOrNil(); err != nil {
+		return nil, err
+	}
+	return p.mcs, nil
+}
+
+func (p *manifestParser) errOrNil() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(p.errs, func(i, j int) bool {
+		a, b := p.errs[i], p.errs[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return p.errs
+}
+
+func (p *manifestParser) parseFile(file string, data []byte) {
+	p.file = file
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		e := ValidationError{File: file, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Message = strings.TrimPrefix(err.Error(), m[0])
+		}
+		p.errs = append(p.errs, e)
+		return
+	}
+	if len(doc.Content) == 0 {
+		return
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		p.errorf(root, "expected a list of docker repos")
+		return
+	}
+	for _, item := range root.Content {
+		mc := p.dockerRepo(item)
+		if mc.DockerRepo == "" {
+			continue
+		}
+		p.mcs = append(p.mcs, mc)
+	}
+}
+
+// dockerRepo validates a docker repo and its manifests
+func (p *manifestParser) dockerRepo(node *yaml.Node) ManifestConfig {
+	fields := p.fields(node, "docker_repo", "manifests")
+	if fields == nil {
+		return ManifestConfig{}
+	}
+	mc := ManifestConfig{DockerRepo: p.requiredString(node, fields, "docker_repo")}
+	if mc.isRegex() {
+		if _, err := mc.regex(); err != nil {
+			p.errorf(fields["docker_repo"], "docker_repo isn't a valid regex: %s", err)
+		}
+	} else if _, err := path.Match(mc.DockerRepo, ""); err != nil {
+		p.errorf(fields["docker_repo"], "docker_repo isn't a valid glob: %s", err)
+	}
+
+	manifests, ok := fields["manifests"]
+	switch {
+	case !ok:
+		p.errorf(node, "manifests is required")
+	case manifests.Kind != yaml.SequenceNode || len(manifests.Content) == 0:
+		p.errorf(manifests, "manifests must be a list of at least one manifest")
+	default:
+		for _, m := range manifests.Content {
+			if entry, ok := p.manifestEntry(mc, m); ok {
+				mc.Manifests = append(mc.Manifests, entry)
+			}
+		}
+	}
+	return mc
+}
+
+func (p *manifestParser) manifestEntry(mc ManifestConfig, node *yaml.Node) (ManifestEntry, bool) {
+	fields := p.fields(node, "config_repo", "file", "base_branch", "pull_request")
+	if fields == nil {
+		return ManifestEntry{}, false
+	}
+	entry := ManifestEntry{
+		ConfigRepo: p.requiredString(node, fields, "config_repo"),
+		File:       p.requiredString(node, fields, "file"),
+		BaseBranch: p.requiredString(node, fields, "base_branch"),
+		Source:     fmt.Sprintf("%s:%d", p.file, node.Line),
+	}
+	// Templates are checked by rendering them with the names of the variables they can use,
+	// which aren't known when the docker repo's regex is invalid
+	vars := mc.templateVars()
+	if !mc.isRegex() || isValidRegex(mc) {
+		for key, value := range map[string]string{"config_repo": entry.ConfigRepo, "file": entry.File, "base_branch": entry.BaseBranch} {
+			if _, err := renderTemplate(value, vars); err != nil {
+				p.errorf(fields[key], "%s has an invalid template: %s", key, err)
+			}
+		}
+	}
+	if configRepo, err := renderTemplate(entry.ConfigRepo, vars); entry.ConfigRepo != "" && err == nil {
+		if owner, name := parseRepo(configRepo); owner == "" || name == "" || strings.Count(configRepo, "/") != 1 {
+			p.errorf(fields["config_repo"], "config_repo must be owner/name, got %q", entry.ConfigRepo)
+		}
+	}
+	if pr, ok := fields["pull_request"]; ok {
+		if err := pr.Decode(&entry.PullRequest); err != nil || pr.Kind != yaml.ScalarNode {
+			p.errorf(pr, "pull_request must be true or false, got %q", pr.Value)
+		}
+	}
+	p.entries = append(p.entries, manifestEntryNode{DockerRepo: mc.DockerRepo, Entry: entry, file: p.file, node: node})
+	return entry, true
+}
+
+// fields returns the values of a mapping by key, and reports unknown and duplicate keys.
+// It returns nil if the node isn't a mapping.
+func (p *manifestParser) fields(node *yaml.Node, known ...string) map[string]*yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		p.errorf(node, "expected a mapping of %s", strings.Join(known, ", "))
+		return nil
+	}
+	fields := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if !contains(known, key.Value) {
+			p.errorf(key, "unknown field %q, expected one of %s", key.Value, strings.Join(known, ", "))
+			continue
+		}
+		if _, ok := fields[key.Value]; ok {
+			p.errorf(key, "%s is defined more than once", key.Value)
+			continue
+		}
+		fields[key.Value] = value
+	}
+	return fields
+}
+
+func (p *manifestParser) requiredString(node *yaml.Node, fields map[string]*yaml.Node, key string) string {
+	value, ok := fields[key]
+	switch {
+	case !ok:
+		p.errorf(node, "%s is required", key)
+	case value.Kind != yaml.ScalarNode:
+		p.errorf(value, "%s must be a string", key)
+	case value.Value == "":
+		p.errorf(value, "%s is required", key)
+	default:
+		return value.Value
+	}
+	return ""
+}
+
+func (p *manifestParser) errorf(node *yaml.Node, format string, args ...interface{}) {
+	p.errs = append(p.errs, ValidationError{
+		File:    p.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func isValidRegex(mc ManifestConfig) bool {
+	_, err := mc.regex()
+	return err == nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
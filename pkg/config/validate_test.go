@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/gh"
+	"github.com/google/go-github/v31/github"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		manifest string
+		expected ValidationErrors
+	}{
+		{validManifest, nil},
+		{"", nil},
+		{`
+- docker_repo: celfring/guestbook
+  manifests:
+    - file: charts/guestbook/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+      pullrequest: true
+`, ValidationErrors{{Line: 7, Column: 7, Message: `unknown field "pullrequest", expected one of config_repo, file, base_branch, pull_request`}}},
+		{`
+- docker_repo: celfring/guestbook
+  manifests:
+    - file: charts/guestbook/values.yaml
+      base_branch: master
+      pull_request: yes please
+- docker_repo: celfring/k8s-demo
+  manifests:
+    - file: charts/k8s-demo/values.yaml
+      config_repo: argocd-demo
+      base_branch: ""
+- manifests:
+    - file: charts/guestbook/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+- docker_repo: celfring/guestbook
+  manifests:
+    - file: charts/guestbook/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+- docker_repo: celfring/empty
+  manifests: []
+`, ValidationErrors{
+			{Line: 4, Column: 7, Message: "config_repo is required"},
+			{Line: 6, Column: 21, Message: `pull_request must be true or false, got "yes please"`},
+			{Line: 10, Column: 20, Message: `config_repo must be owner/name, got "argocd-demo"`},
+			{Line: 11, Column: 20, Message: "base_branch is required"},
+			{Line: 12, Column: 3, Message: "docker_repo is required"},
+			{Line: 22, Column: 14, Message: "manifests must be a list of at least one manifest"},
+		}},
+		{`
+- docker_repo: /^celfring/(?P<app>[a-z]+$/
+  manifests:
+    - file: charts/{{.app}}/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+- docker_repo: "celfring/["
+  manifests:
+    - file: charts/{{.name}}/values.yaml
+      config_repo: caitlin615/argocd-demo
+      base_branch: master
+- docker_repo: /^(?P<team>[a-z]+)/(?P<app>[a-z]+)$/
+  manifests:
+    - file: charts/{{.ap}}/values.yaml
+      config_repo: "{{.team}}"
+      base_branch: "{{.team"
+    - file: charts/{{.app}}/values.yaml
+      config_repo: "{{.team}}/configs"
+      base_branch: master
+`, ValidationErrors{
+			{Line: 2, Column: 16, Message: "docker_repo isn't a valid regex: error parsing regexp: missing closing ): `^celfring/(?P<app>[a-z]+$`"},
+			{Line: 7, Column: 16, Message: "docker_repo isn't a valid glob: syntax error in pattern"},
+			{Line: 14, Column: 13, Message: `file has an invalid template: template: :1:9: executing "" at <.ap>: map has no entry for key "ap"`},
+			{Line: 15, Column: 20, Message: `config_repo must be owner/name, got "{{.team}}"`},
+			{Line: 16, Column: 20, Message: `base_branch has an invalid template: template: :1: unclosed action`},
+		}},
+		{"docker_repo: celfring/guestbook\n", ValidationErrors{{Line: 1, Column: 1, Message: "expected a list of docker repos"}}},
+		{"- docker_repo: [\n", ValidationErrors{{Line: 1, Message: "did not find expected node content"}}},
+	}
+
+	for _, test := range tests {
+		for i := range test.expected {
+			test.expected[i].File = "manifest.yaml"
+		}
+		_, err := parseManifest("manifest.yaml", []byte(test.manifest))
+		var got ValidationErrors
+		if err != nil {
+			got = err.(ValidationErrors)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s\nexpected: %v\ngot: %v", test.manifest, test.expected, got)
+		}
+	}
+}
+
+func TestParseManifestMalformed(t *testing.T) {
+	// Malformed YAML is an error, rather than a panic that would take down the server
+	_, err := parseManifest("manifest.yaml", []byte("0: [:!00 \xef"))
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].File != "manifest.yaml" {
+		t.Errorf("expected a ValidationError for manifest.yaml, got: %v", err)
+	}
+}
+
+func TestParseManifestDecodes(t *testing.T) {
+	data, err := ioutil.ReadFile("manifest-test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseManifest("manifest-test.yaml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ManifestConfigs{
+		{DockerRepo: "celfring/guestbook", Manifests: []ManifestEntry{
+			{File: "charts/guestbook/values.yaml", ConfigRepo: "caitlin615/argocd-demo", BaseBranch: "master", PullRequest: false, Source: "manifest-test.yaml:4"},
+		}},
+		{DockerRepo: "celfring/k8s-demo", Manifests: []ManifestEntry{
+			{File: "charts/k8s-demo/values.yaml", ConfigRepo: "caitlin615/argocd-demo", BaseBranch: "master", PullRequest: true, Source: "manifest-test.yaml:11"},
+		}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blanche-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "manifest.yaml")
+	if err := ioutil.WriteFile(path, []byte(validManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateManifest(path, false); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateManifest("manifest-no.yaml", false); err == nil {
+		t.Error("should have returned an error, but got nil")
+	}
+}
+
+func TestValidateGitManifest(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	gh.SetClient(client)
+	defer gh.SetClient(nil)
+
+	mux.HandleFunc("/repos/o/configs/git/refs/heads/master", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/master", "object": {"sha": "abc"}}`)
+	})
+	file := func(path, content string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"type": "file", "path": %q, "encoding": "base64", "content": %q}`, path, base64.StdEncoding.EncodeToString([]byte(content)))
+		}
+	}
+	mux.HandleFunc("/repos/o/configs/contents/manifest.yaml", file("manifest.yaml", validManifest))
+	mux.HandleFunc("/repos/o/configs/contents/invalid.yaml", file("invalid.yaml", invalidManifest))
+
+	if err := ValidateGitManifest("o/configs", "master", "manifest.yaml", false); err != nil {
+		t.Error(err)
+	}
+	expected := "o/configs@master:invalid.yaml:4:7: base_branch is required\n" +
+		`o/configs@master:invalid.yaml:5:20: config_repo must be owner/name, got "argocd-demo"`
+	if err := ValidateGitManifest("o/configs", "master", "invalid.yaml", false); err == nil || err.Error() != expected {
+		t.Errorf("expected error: %s, got: %v", expected, err)
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	tests := []struct {
+		err      ValidationError
+		expected string
+	}{
+		{ValidationError{File: "m.yaml", Line: 3, Column: 7, Message: "oops"}, "m.yaml:3:7: oops"},
+		{ValidationError{File: "m.yaml", Line: 3, Message: "oops"}, "m.yaml:3: oops"},
+		{ValidationError{File: "m.yaml", Message: "oops"}, "m.yaml: oops"},
+	}
+	for _, test := range tests {
+		if got := test.err.Error(); got != test.expected {
+			t.Errorf("expected: %s, got: %s", test.expected, got)
+		}
+	}
+}
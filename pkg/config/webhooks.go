@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	genericWebhooks     GenericWebhooks
+	genericWebhooksErr  error
+	genericWebhooksOnce sync.Once
+)
+
+type GenericWebhooks []GenericWebhook
+
+// GenericWebhook maps the body of a webhook from any system to a docker image tag.
+// Each field is a path into the JSON body, like `$.image.tag` or `resources[0].tag`
+type GenericWebhook struct {
+	Name       string `yaml:"name"`
+	Repository string `yaml:"repository"`
+	Tag        string `yaml:"tag"`
+	Digest     string `yaml:"digest"`
+}
+
+// LoadGenericWebhooks reads GENERIC_WEBHOOKS_PATH the first time it's called, and returns an error
+// if it's invalid. It's fine for the file not to exist, since generic webhooks are optional.
+func LoadGenericWebhooks() error {
+	genericWebhooksOnce.Do(func() {
+		path := getEnvDefault("GENERIC_WEBHOOKS_PATH", "webhooks.yaml")
+		genericWebhooks, genericWebhooksErr = readGenericWebhooks(path)
+		switch {
+		case os.IsNotExist(genericWebhooksErr):
+			log.Printf("%s doesn't exist, generic webhooks are disabled", path)
+			genericWebhooksErr = nil
+		case genericWebhooksErr != nil:
+			log.Printf("failed to load %s, generic webhooks are disabled: %s", path, genericWebhooksErr)
+		}
+	})
+	return genericWebhooksErr
+}
+
+func GetGenericWebhook(name string) *GenericWebhook {
+	if err := LoadGenericWebhooks(); err != nil {
+		return nil
+	}
+	return genericWebhooks.getWebhook(name)
+}
+
+func (gws *GenericWebhooks) getWebhook(name string) *GenericWebhook {
+	for _, gw := range *gws {
+		if gw.Name == name {
+			return &gw
+		}
+	}
+	return nil
+}
+
+// readGenericWebhooks strictly parses the file, so a misspelled field isn't silently ignored
+func readGenericWebhooks(filename string) (GenericWebhooks, error) {
+	yamlFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var gws GenericWebhooks
+	if err := yaml.UnmarshalStrict(yamlFile, &gws); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	names := map[string]bool{}
+	for i, gw := range gws {
+		switch {
+		case gw.Name == "":
+			return nil, fmt.Errorf("%s: webhook %d: name is required", filename, i+1)
+		case names[gw.Name]:
+			return nil, fmt.Errorf("%s: webhook %s is defined more than once", filename, gw.Name)
+		case gw.Repository == "":
+			return nil, fmt.Errorf("%s: webhook %s: repository is required", filename, gw.Name)
+		case gw.Tag == "":
+			return nil, fmt.Errorf("%s: webhook %s: tag is required", filename, gw.Name)
+		}
+		names[gw.Name] = true
+	}
+	return gws, nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// resetGenericWebhooks makes the next call to GetGenericWebhook load the file again
+func resetGenericWebhooks() {
+	genericWebhooks = nil
+	genericWebhooksErr = nil
+	genericWebhooksOnce = sync.Once{}
+}
+
+func TestGetGenericWebhook(t *testing.T) {
+	os.Setenv("GENERIC_WEBHOOKS_PATH", "webhooks-test.yaml")
+	resetGenericWebhooks()
+
+	tests := []struct {
+		value    string
+		expected *GenericWebhook
+	}{
+		{"jenkins", &GenericWebhook{Name: "jenkins", Repository: "$.image.name", Tag: "$.image.tag", Digest: "$.image.digest"}},
+		{"buildkite", &GenericWebhook{Name: "buildkite", Repository: "build.meta_data.image", Tag: "build.meta_data.tags[0]"}},
+		{"no-entry", nil},
+	}
+
+	for _, test := range tests {
+		gw := GetGenericWebhook(test.value)
+		if !reflect.DeepEqual(gw, test.expected) {
+			t.Errorf("expected: %+v got: %+v", test.expected, gw)
+		}
+	}
+
+	// A missing file disables generic webhooks, without an error
+	os.Setenv("GENERIC_WEBHOOKS_PATH", "webhooks-no.yaml")
+	resetGenericWebhooks()
+	if err := LoadGenericWebhooks(); err != nil {
+		t.Errorf("expected no error, got: %s", err)
+	}
+	if gw := GetGenericWebhook("jenkins"); gw != nil {
+		t.Errorf("expected nil webhook, got %v", gw)
+	}
+	os.Setenv("GENERIC_WEBHOOKS_PATH", "webhooks-test.yaml")
+	resetGenericWebhooks()
+}
+
+func TestReadGenericWebhooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blanche-webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "webhooks.yaml")
+
+	tests := []struct {
+		webhooks string
+		expected string
+	}{
+		{"- name: jenkins\n  repository: $.image.name\n  tag: $.image.tag\n", ""},
+		{"- name: jenkins\n  repostiory: $.image.name\n  tag: $.image.tag\n", path + ": yaml: unmarshal errors:\n  line 2: field repostiory not found in type config.GenericWebhook"},
+		{"- repository: $.image.name\n  tag: $.image.tag\n", path + ": webhook 1: name is required"},
+		{"- name: jenkins\n  tag: $.image.tag\n", path + ": webhook jenkins: repository is required"},
+		{"- name: jenkins\n  repository: $.image.name\n", path + ": webhook jenkins: tag is required"},
+		{"- name: jenkins\n  repository: $.image.name\n  tag: $.image.tag\n- name: jenkins\n  repository: $.name\n  tag: $.tag\n", path + ": webhook jenkins is defined more than once"},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(path, []byte(test.webhooks), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if _, err := readGenericWebhooks(path); err != nil {
+			got = err.Error()
+		}
+		if got != test.expected {
+			t.Errorf("expected: %q, got: %q", test.expected, got)
+		}
+	}
+}
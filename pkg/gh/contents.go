@@ -0,0 +1,81 @@
+package gh
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v31/github"
+)
+
+// File is a file read from a repo
+type File struct {
+	Path string
+	Data []byte
+}
+
+// BranchSHA returns the SHA of the commit at the head of the branch
+func BranchSHA(repoOwner, repoName, branch string) (string, error) {
+	ref, _, err := getClient().Git.GetRef(ctx, repoOwner, repoName, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	return ref.GetObject().GetSHA(), nil
+}
+
+// GetFiles reads the files at p on ref. p is either a single file, a directory, whose *.yaml
+// and *.yml files are read, or a glob in a directory, like `manifests/*.yaml`.
+func GetFiles(repoOwner, repoName, ref, p string) ([]File, error) {
+	client := getClient()
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+	dir, patterns := p, []string{"*.yaml", "*.yml"}
+	if strings.ContainsAny(p, "*?[") {
+		dir, patterns = path.Dir(p), []string{path.Base(p)}
+		if strings.ContainsAny(dir, "*?[") {
+			return nil, fmt.Errorf("only the file name can be a glob, got %s", p)
+		}
+	}
+
+	file, listing, _, err := client.Repositories.GetContents(ctx, repoOwner, repoName, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if file != nil {
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		return []File{{Path: file.GetPath(), Data: []byte(content)}}, nil
+	}
+
+	var files []File
+	for _, entry := range listing {
+		if entry.GetType() != "file" || !matchesAny(patterns, entry.GetName()) {
+			continue
+		}
+		file, _, _, err := client.Repositories.GetContents(ctx, repoOwner, repoName, entry.GetPath(), opts)
+		if err != nil {
+			return nil, err
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: entry.GetPath(), Data: []byte(content)})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %s in %s/%s", p, repoOwner, repoName)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package gh
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBranchSHA(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+	defer useClient(client)()
+
+	mux.HandleFunc("/repos/o/r/git/refs/heads/master", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/master", "object": {"type": "commit", "sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}}`)
+	})
+
+	sha, err := BranchSHA("o", "r", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "aa218f56b14c9653891f9e74264a383fa43fefbd"; sha != expected {
+		t.Errorf("expected: %s, got: %s", expected, sha)
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+	defer useClient(client)()
+
+	file := func(path, content string) string {
+		return fmt.Sprintf(`{"type": "file", "path": %q, "encoding": "base64", "content": %q}`, path, base64.StdEncoding.EncodeToString([]byte(content)))
+	}
+	mux.HandleFunc("/repos/o/r/contents/manifests", func(w http.ResponseWriter, r *http.Request) {
+		if ref := r.URL.Query().Get("ref"); ref != "abc" {
+			t.Errorf("expected files to be read at ref abc, got: %s", ref)
+		}
+		fmt.Fprint(w, `[
+		  {"type": "file", "name": "team-b.yml", "path": "manifests/team-b.yml"},
+		  {"type": "file", "name": "team-a.yaml", "path": "manifests/team-a.yaml"},
+		  {"type": "file", "name": "README.md", "path": "manifests/README.md"},
+		  {"type": "dir", "name": "old.yaml", "path": "manifests/old.yaml"}
+		]`)
+	})
+	mux.HandleFunc("/repos/o/r/contents/manifests/team-a.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, file("manifests/team-a.yaml", "a"))
+	})
+	mux.HandleFunc("/repos/o/r/contents/manifests/team-b.yml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, file("manifests/team-b.yml", "b"))
+	})
+
+	tests := []struct {
+		path     string
+		expected []File
+	}{
+		{"manifests", []File{{"manifests/team-a.yaml", []byte("a")}, {"manifests/team-b.yml", []byte("b")}}},
+		{"manifests/*.yml", []File{{"manifests/team-b.yml", []byte("b")}}},
+		{"manifests/team-a.yaml", []File{{"manifests/team-a.yaml", []byte("a")}}},
+	}
+	for _, test := range tests {
+		got, err := GetFiles("o", "r", "abc", test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s | expected: %+v, got: %+v", test.path, test.expected, got)
+		}
+	}
+
+	for _, path := range []string{"manifests/*.json", "*/team-a.yaml", "missing.yaml"} {
+		if _, err := GetFiles("o", "r", "abc", path); err == nil {
+			t.Errorf("%s | should have returned an error, but got nil", path)
+		}
+	}
+}
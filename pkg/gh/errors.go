@@ -0,0 +1,52 @@
+package gh
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v31/github"
+)
+
+// IsRetryable returns true if err is likely to be transient,
+// and the update may succeed if it is tried again later
+func IsRetryable(err error) bool {
+	var rateLimitErr *github.RateLimitError
+	var abuseRateLimitErr *github.AbuseRateLimitError
+	var responseErr *github.ErrorResponse
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &rateLimitErr), errors.As(err, &abuseRateLimitErr), isNonFastForward(err):
+		return true
+	case errors.As(err, &responseErr):
+		if responseErr.Response == nil {
+			return false
+		}
+		code := responseErr.Response.StatusCode
+		return code >= http.StatusInternalServerError ||
+			code == http.StatusTooManyRequests ||
+			code == http.StatusConflict
+	case errors.As(err, &netErr):
+		return true
+	}
+	// This includes the tag already being up to date
+	return false
+}
+
+// isNonFastForward returns true if err is GitHub rejecting a ref update
+// because the branch was updated since the commit was created
+func isNonFastForward(err error) bool {
+	var responseErr *github.ErrorResponse
+	if !errors.As(err, &responseErr) || responseErr.Response == nil {
+		return false
+	}
+	switch responseErr.Response.StatusCode {
+	case http.StatusConflict:
+		return true
+	case http.StatusUnprocessableEntity:
+		return strings.Contains(strings.ToLower(responseErr.Message), "fast forward")
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v31/github"
+)
+
+func TestIsRetryable(t *testing.T) {
+	responseErr := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code}}
+	}
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{responseErr(http.StatusBadGateway), true},
+		{responseErr(http.StatusTooManyRequests), true},
+		{responseErr(http.StatusConflict), true},
+		{fmt.Errorf("wrapped: %w", responseErr(http.StatusServiceUnavailable)), true},
+		{&github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnprocessableEntity}, Message: "Update is not a fast forward"}, true},
+		{&github.RateLimitError{}, true},
+		{&github.AbuseRateLimitError{}, true},
+		{&url.Error{Op: "Get", URL: "https://api.github.com", Err: errors.New("connection reset")}, true},
+		{responseErr(http.StatusNotFound), false},
+		{responseErr(http.StatusUnprocessableEntity), false},
+		{&github.ErrorResponse{}, false},
+		{errors.New(ErrTagMatchesCurrentTag), false},
+		{errors.New(ErrTagPrecedesCurrentTag), false},
+	}
+
+	for _, test := range tests {
+		if got := IsRetryable(test.err); got != test.expected {
+			t.Errorf("IsRetryable(%v) | expected: %t, got: %t", test.err, test.expected, got)
+		}
+	}
+}
+
+func TestIsNonFastForward(t *testing.T) {
+	responseErr := func(code int, message string) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code}, Message: message}
+	}
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{responseErr(http.StatusUnprocessableEntity, "Update is not a fast forward"), true},
+		{responseErr(http.StatusConflict, ""), true},
+		{responseErr(http.StatusUnprocessableEntity, "Reference does not exist"), false},
+		{responseErr(http.StatusBadGateway, ""), false},
+		{errors.New("Update is not a fast forward"), false},
+	}
+
+	for _, test := range tests {
+		if got := isNonFastForward(test.err); got != test.expected {
+			t.Errorf("isNonFastForward(%+v) | expected: %t, got: %t", test.err, test.expected, got)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"regexp"
 	"time"
@@ -18,6 +19,12 @@ import (
 var ctx = context.Background()
 var _client *github.Client
 
+var (
+	ErrNoImageTag        = errors.New("Manifest doesn't have an image.tag")
+	ErrImageTagNotString = errors.New("Manifest's image.tag isn't a string, it may need to be quoted")
+	ErrNotAFile          = errors.New("Manifest is a directory, not a file")
+)
+
 const (
 	ErrTagMatchesCurrentTag  = "New tag matches the tag in existing manifest"
 	ErrTagPrecedesCurrentTag = "New tag precedes existing tag"
@@ -25,6 +32,9 @@ const (
 	// TODO: These should be dynamic
 	GitCommitAuthorName  = "Caitlin Elfring"
 	GitCommitAuthorEmail = "celfring@renttherunway.com"
+
+	// MaxConflictAttempts is how many times a commit is rebuilt when its branch is updated while pushing it
+	MaxConflictAttempts = 3
 )
 
 type gitUpdate struct {
@@ -42,6 +52,8 @@ type gitUpdate struct {
 	targetBranch string
 	baseRef      string
 	targetRef    string
+	// targetExisted is true if the target branch existed before this update, like when it's being retried
+	targetExisted bool
 }
 
 func CreateGithubClient(accessToken string) *github.Client {
@@ -57,6 +69,19 @@ func CreateGithubClient(accessToken string) *github.Client {
 	return _client
 }
 
+// SetClient sets the client that's used to talk to GitHub, instead of the one CreateGithubClient creates
+func SetClient(client *github.Client) {
+	_client = client
+}
+
+// getClient returns the client, creating it with GITHUB_ACCESS_TOKEN if it hasn't been yet
+func getClient() *github.Client {
+	if _client == nil {
+		_client = CreateGithubClient(os.Getenv("GITHUB_ACCESS_TOKEN"))
+	}
+	return _client
+}
+
 func NewGitUpdates(repoOwner, repoName, manifest, baseBranch, dockerImage, tag string, pullRequest, closeOutdatedPRs bool) *gitUpdate {
 	g := gitUpdate{
 		RepoOwner:        repoOwner,
@@ -77,50 +102,82 @@ func NewGitUpdates(repoOwner, repoName, manifest, baseBranch, dockerImage, tag s
 	g.targetRef = "refs/heads/" + g.targetBranch
 	g.baseRef = "refs/heads/" + g.BaseBranch
 
-	if _client == nil {
-		_client = CreateGithubClient(os.Getenv("GITHUB_ACCESS_TOKEN"))
-	}
-	g.client = _client
+	g.client = getClient()
 
 	return &g
 }
 
-// CreateUpdates will create PRs/push commits for the given ManifestUpdate
-func (g *gitUpdate) CreateUpdates() (err error) {
-	ref, err := g.createRef(g.baseRef, g.targetRef)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	tree, err := g.newTreeWithChanges(ref)
-	if err != nil {
-		log.Println(err)
-		return
+// CreateUpdates will create PRs/push commits for the given ManifestUpdate.
+// It returns the URL of the PR, or of the commit when it was pushed directly to the base branch.
+// When the PR was opened but closing the PRs it supersedes failed, both its URL and the error are returned.
+func (g *gitUpdate) CreateUpdates() (url string, err error) {
+	// Updates to the same file can't be pushed concurrently, or the second push won't be a fast forward
+	unlock := lockFile(g.lockKey())
+	defer unlock()
+
+	var commit *github.Commit
+	for attempt := 1; ; attempt++ {
+		if commit, err = g.commitChanges(); err == nil {
+			break
+		}
+		if g.PullRequest && g.targetExisted && err.Error() == ErrTagMatchesCurrentTag {
+			// An earlier attempt pushed the commit, but failed before the PR was opened
+			log.Printf("%s already has %s:%s, opening its PR", g.targetBranch, g.DockerImage, g.Tag)
+			err = nil
+			break
+		}
+		if !isNonFastForward(err) || attempt >= MaxConflictAttempts {
+			log.Println(err)
+			return
+		}
+		// Something else pushed to the branch, so the file needs to be read again
+		log.Printf("%s was updated while pushing %s:%s, rebuilding the commit: %s", g.targetBranch, g.DockerImage, g.Tag, err)
 	}
 
-	if err = g.pushCommit(ref, tree); err != nil {
-		log.Println(err)
-		return
-	}
 	if g.PullRequest {
-		prURL, errInner := g.createPR(g.targetRef, g.baseRef)
+		prURL, errInner := g.openPR()
 		if errInner != nil {
 			log.Println(errInner)
-			return errInner
+			return "", errInner
 		}
 		log.Printf("Opened new PR: %s | %+v", prURL, g)
 		if g.CloseOutdatedPRs {
-			if errInner = g.closeOutdatedPRs(prURL); err != nil {
-				return errInner
+			if errInner = g.closeOutdatedPRs(prURL); errInner != nil {
+				return prURL, errInner
 			}
 		}
-	} else {
-		log.Printf("Pushed new commit: %s | %+v", tree.Entries[0].GetURL(), g)
+		return prURL, nil
 	}
+
+	url = commit.GetHTMLURL()
+	if url == "" {
+		url = fmt.Sprintf("https://github.com/%s/%s/commit/%s", g.RepoOwner, g.RepoName, commit.GetSHA())
+	}
+	log.Printf("Pushed new commit: %s | %+v", url, g)
 	return
 }
 
+// commitChanges pushes a commit with the new tag to the target branch,
+// based on the contents of the manifest file at the head of that branch
+func (g *gitUpdate) commitChanges() (*github.Commit, error) {
+	ref, err := g.createRef(g.baseRef, g.targetRef)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := g.newTreeWithChanges(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.pushCommit(ref, tree)
+}
+
+// lockKey identifies the file being updated, across all updates
+func (g *gitUpdate) lockKey() string {
+	return fmt.Sprintf("%s/%s@%s:%s", g.RepoOwner, g.RepoName, g.BaseBranch, g.ManifestFile)
+}
+
 func (g *gitUpdate) createRef(baseBranchRef, targetBranchRef string) (ref *github.Reference, err error) {
 	if ref, _, err = g.client.Git.GetRef(
 		ctx,
@@ -128,6 +185,7 @@ func (g *gitUpdate) createRef(baseBranchRef, targetBranchRef string) (ref *githu
 		g.RepoName,
 		targetBranchRef); err == nil {
 		// ref already exists
+		g.targetExisted = true
 		return ref, nil
 	}
 
@@ -151,6 +209,7 @@ func (g *gitUpdate) createRef(baseBranchRef, targetBranchRef string) (ref *githu
 }
 
 func (g *gitUpdate) getManifestFileContents(ref *github.Reference) (string, error) {
+	// contents is nil when the file is a directory
 	contents, _, _, err := g.client.Repositories.GetContents(
 		ctx,
 		g.RepoOwner,
@@ -161,17 +220,94 @@ func (g *gitUpdate) getManifestFileContents(ref *github.Reference) (string, erro
 	if err != nil {
 		return "", err
 	}
+	if contents == nil {
+		return "", ErrNotAFile
+	}
 	return contents.GetContent()
 }
 
+// CurrentTag returns the image tag in the manifest file at the head of branch
+func CurrentTag(repoOwner, repoName, manifest, branch string) (string, error) {
+	g := NewGitUpdates(repoOwner, repoName, manifest, branch, "", "", false, false)
+	contents, err := g.getManifestFileContents(&github.Reference{Ref: github.String(g.baseRef)})
+	if err != nil {
+		return "", err
+	}
+	return imageTag(contents)
+}
+
+// ReleaseBranchExists returns true if the branch of the pull request that updates the base branch
+// to the image's tag already exists, like when the pull request is open but hasn't been merged yet
+func ReleaseBranchExists(repoOwner, repoName, baseBranch, dockerImage, tag string) (bool, error) {
+	g := NewGitUpdates(repoOwner, repoName, "", baseBranch, dockerImage, tag, true, false)
+	_, resp, err := g.client.Git.GetRef(ctx, repoOwner, repoName, g.targetRef)
+	if err != nil {
+		// GitHub responds with the branches it's a prefix of when there isn't an exact match
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusOK) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CheckManifest returns an error if the repo, the branch, or the manifest file on the branch
+// don't exist, or if the manifest file doesn't have an image tag
+func CheckManifest(repoOwner, repoName, manifest, branch string) error {
+	g := NewGitUpdates(repoOwner, repoName, manifest, branch, "", "", false, false)
+	if _, _, err := g.client.Repositories.Get(ctx, repoOwner, repoName); err != nil {
+		return fmt.Errorf("repo %s/%s: %s", repoOwner, repoName, describeNotFound(err))
+	}
+	if _, _, err := g.client.Repositories.GetBranch(ctx, repoOwner, repoName, branch); err != nil {
+		return fmt.Errorf("branch %s of %s/%s: %s", branch, repoOwner, repoName, describeNotFound(err))
+	}
+	contents, err := g.getManifestFileContents(&github.Reference{Ref: github.String(g.baseRef)})
+	if err != nil {
+		return fmt.Errorf("file %s on %s of %s/%s: %s", manifest, branch, repoOwner, repoName, describeNotFound(err))
+	}
+	if _, err := imageTag(contents); err != nil {
+		return fmt.Errorf("file %s on %s of %s/%s: %s", manifest, branch, repoOwner, repoName, err)
+	}
+	return nil
+}
+
+// describeNotFound shortens 404 errors, which are the usual reason a check fails
+func describeNotFound(err error) string {
+	if e, ok := err.(*github.ErrorResponse); ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound {
+		return "not found"
+	}
+	return err.Error()
+}
+
+func imageTag(data string) (string, error) {
+	var contents struct {
+		Image struct {
+			Tag string `yaml:"tag"`
+		} `yaml:"image"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &contents); err != nil {
+		return "", err
+	}
+	if contents.Image.Tag == "" {
+		return "", ErrNoImageTag
+	}
+	return contents.Image.Tag, nil
+}
+
 func updateImageTag(data string, newTag string) (string, error) {
 	var contents map[interface{}]interface{}
 	if err := yaml.Unmarshal([]byte(data), &contents); err != nil {
 		return "", err
 	}
 
-	imageMap := contents["image"].(map[interface{}]interface{})
-	currentTag := imageMap["tag"].(string)
+	imageMap, ok := contents["image"].(map[interface{}]interface{})
+	if !ok || imageMap["tag"] == nil {
+		return "", ErrNoImageTag
+	}
+	currentTag, ok := imageMap["tag"].(string)
+	if !ok {
+		return "", ErrImageTagNotString
+	}
 	if currentTag == newTag {
 		return "", errors.New(ErrTagMatchesCurrentTag)
 	}
@@ -220,11 +356,11 @@ func (g *gitUpdate) newTreeWithChanges(ref *github.Reference) (tree *github.Tree
 	return tree, err
 }
 
-func (g *gitUpdate) pushCommit(ref *github.Reference, tree *github.Tree) (err error) {
+func (g *gitUpdate) pushCommit(ref *github.Reference, tree *github.Tree) (*github.Commit, error) {
 	// Get the parent commit to attach the commit to.,
 	parent, _, err := g.client.Repositories.GetCommit(ctx, g.RepoOwner, g.RepoName, ref.Object.GetSHA())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// This is not always populated, but is needed.
 	parent.Commit.SHA = parent.SHA
@@ -245,13 +381,15 @@ func (g *gitUpdate) pushCommit(ref *github.Reference, tree *github.Tree) (err er
 	}
 	newCommit, _, err := g.client.Git.CreateCommit(ctx, g.RepoOwner, g.RepoName, commit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Attach the commit to the master branch.
 	ref.Object.SHA = newCommit.SHA
-	_, _, err = g.client.Git.UpdateRef(ctx, g.RepoOwner, g.RepoName, ref, false)
-	return err
+	if _, _, err = g.client.Git.UpdateRef(ctx, g.RepoOwner, g.RepoName, ref, false); err != nil {
+		return nil, err
+	}
+	return newCommit, nil
 }
 
 // createPR creates a pull request. Based on: https://godoc.org/github.com/google/go-github/github#example-PullRequestsService-Create
@@ -271,6 +409,24 @@ func (g *gitUpdate) createPR(head, base string) (url string, err error) {
 	return pr.GetHTMLURL(), nil
 }
 
+// openPR opens a PR for the target branch, or returns the one that's already open,
+// like when an earlier attempt opened it but failed before it got the response
+func (g *gitUpdate) openPR() (string, error) {
+	url, err := g.createPR(g.targetRef, g.baseRef)
+	if err == nil {
+		return url, nil
+	}
+	prs, _, listErr := g.client.PullRequests.List(ctx, g.RepoOwner, g.RepoName, &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", g.RepoOwner, g.targetBranch),
+		Base:  g.BaseBranch,
+	})
+	if listErr != nil || len(prs) == 0 {
+		return "", err
+	}
+	return prs[0].GetHTMLURL(), nil
+}
+
 func (g *gitUpdate) closeOutdatedPRs(supersededPRURL string) error {
 	prsToClose, err := g.getOutdatedPRs()
 	if err != nil {
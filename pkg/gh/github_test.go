@@ -31,6 +31,42 @@ func TestUpdateImageTag(t *testing.T) {
 	}
 }
 
+func TestUpdateImageTagInvalid(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected error
+	}{
+		{"replicas: 2\n", ErrNoImageTag},
+		{"image: myRepo:v1\n", ErrNoImageTag},
+		{"image:\n  repo: myRepo\n", ErrNoImageTag},
+		{"image:\n  tag: 1.2\n", ErrImageTagNotString},
+	}
+	for _, test := range tests {
+		if _, err := updateImageTag(test.value, "v2"); err != test.expected {
+			t.Errorf("expected error: %v, got: %v", test.expected, err)
+		}
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		err      error
+	}{
+		{"image:\n  tag: v1\n  repo: myRepo\n", "v1", nil},
+		{"image:\n  repo: myRepo\n", "", ErrNoImageTag},
+		{"replicas: 2\n", "", ErrNoImageTag},
+	}
+
+	for _, test := range tests {
+		got, err := imageTag(test.value)
+		if got != test.expected || err != test.err {
+			t.Errorf("expected: %s (%v), got: %s (%v)", test.expected, test.err, got, err)
+		}
+	}
+}
+
 func TestIsOlderVersionBumpPR(t *testing.T) {
 	tests := []struct {
 		image, tag string
@@ -149,6 +185,14 @@ func TestGitUpdate_getManifestFileContents(t *testing.T) {
 	if expected != content {
 		t.Errorf("expected %s, got %s", expected, content)
 	}
+
+	mux.HandleFunc("/repos/o/r/contents/charts/r", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"type": "file", "name": "values.yaml", "path": "charts/r/values.yaml"}]`)
+	})
+	g.ManifestFile = "charts/r"
+	if _, err := g.getManifestFileContents(ref); err != ErrNotAFile {
+		t.Errorf("expected error: %s, got: %v", ErrNotAFile, err)
+	}
 }
 
 func TestGitUpdate_newTreeWithChanges(t *testing.T) {
@@ -277,9 +321,13 @@ func TestGitUpdate_pushCommit(t *testing.T) {
 			},
 		},
 	}
-	if err := g.pushCommit(ref, tree); err != nil {
+	commit, err := g.pushCommit(ref, tree)
+	if err != nil {
 		t.Error(err)
 	}
+	if commit.GetSHA() != "newCommitSha" {
+		t.Errorf("expected commit: %s, got: %s", "newCommitSha", commit.GetSHA())
+	}
 }
 
 func TestGitUpdate_createPR(t *testing.T) {
@@ -324,6 +372,126 @@ func TestGitUpdate_createPR(t *testing.T) {
 	}
 }
 
+func TestGitUpdate_openPR(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+
+	mux.HandleFunc("/repos/o/r/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "A pull request already exists for o:auto-release/master/o/r-v2."}`)
+			return
+		}
+		if head := r.URL.Query().Get("head"); head != "o:auto-release/master/o/r-v2" {
+			t.Errorf("expected head: o:auto-release/master/o/r-v2, got: %s", head)
+		}
+		fmt.Fprint(w, `[{"number": 1, "html_url": "https://github.com/o/r/pulls/1"}]`)
+	})
+
+	g := newGitUpdate()
+	g.client = client
+
+	url, err := g.openPR()
+	if err != nil {
+		t.Error(err)
+	}
+	if expected := "https://github.com/o/r/pulls/1"; url != expected {
+		t.Errorf("expected url: %s, got: %s", expected, url)
+	}
+}
+
+func TestGitUpdate_CreateUpdatesCloseOutdatedPRsError(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+
+	// The PR's branch already has the new tag, so only the PR is opened
+	mux.HandleFunc("/repos/o/r/git/refs/heads/auto-release/master/o/r-v2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/auto-release/master/o/r-v2", "object": {"sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}}`)
+	})
+	mux.HandleFunc("/repos/o/r/contents/charts/r/values.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"type": "file", "content": "image:\n  tag: v2\n"}`)
+	})
+	mux.HandleFunc("/repos/o/r/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `{"number": 2, "html_url": "https://github.com/o/r/pulls/2"}`)
+			return
+		}
+		fmt.Fprint(w, `[{"number": 1, "title": "[auto-release] o/r:v1 for master"}]`)
+	})
+	mux.HandleFunc("/repos/o/r/pulls/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	g := newGitUpdate()
+	g.client = client
+
+	url, err := g.CreateUpdates()
+	if err == nil {
+		t.Error("expected the error from closing the outdated PR, got nil")
+	}
+	if expected := "https://github.com/o/r/pulls/2"; url != expected {
+		t.Errorf("expected url: %s, got: %s", expected, url)
+	}
+}
+
+func TestGitUpdate_CreateUpdatesNonFastForward(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+
+	var contentReads, refUpdates int
+	mux.HandleFunc("/repos/o/r/git/refs/heads/master", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			refUpdates++
+			// The first push loses the race with another commit
+			if refUpdates == 1 {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"message": "Update is not a fast forward"}`)
+				return
+			}
+		}
+		fmt.Fprint(w, `{
+		  "ref": "refs/heads/master",
+		  "object": {"type": "commit", "sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}
+		}`)
+	})
+	mux.HandleFunc("/repos/o/r/contents/charts/r/values.yaml", func(w http.ResponseWriter, r *http.Request) {
+		contentReads++
+		fmt.Fprint(w, `{"type": "file", "encoding": "base64", "content": "aW1hZ2U6CiAgdGFnOiB2MQo="}`)
+	})
+	mux.HandleFunc("/repos/o/r/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "5c6780ad2c68743383b740fd1dab6f6a33202b11", "tree": [{"path": "charts/r/values.yaml"}]}`)
+	})
+	mux.HandleFunc("/repos/o/r/commits/aa218f56b14c9653891f9e74264a383fa43fefbd", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "aa218f56b14c9653891f9e74264a383fa43fefbd", "commit": {}}`)
+	})
+	mux.HandleFunc("/repos/o/r/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "newCommitSha"}`)
+	})
+
+	g := newGitUpdate()
+	g.client = client
+	g.PullRequest = false
+	g.targetBranch = g.BaseBranch
+	g.targetRef = g.baseRef
+
+	url, err := g.CreateUpdates()
+	if err != nil {
+		t.Error(err)
+	}
+	if expected := "https://github.com/o/r/commit/newCommitSha"; url != expected {
+		t.Errorf("expected url: %s, got: %s", expected, url)
+	}
+	if refUpdates != 2 {
+		t.Errorf("expected 2 ref updates, got: %d", refUpdates)
+	}
+	if contentReads != 2 {
+		t.Errorf("expected the manifest file to be read again after the conflict, got %d reads", contentReads)
+	}
+}
+
 func newGitUpdate() *gitUpdate {
 	return NewGitUpdates(
 		"o",
@@ -335,3 +503,81 @@ func newGitUpdate() *gitUpdate {
 		true,
 		true)
 }
+
+func TestCheckManifest(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+	defer useClient(client)()
+
+	mux.HandleFunc("/repos/o/r", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"full_name": "o/r"}`)
+	})
+	mux.HandleFunc("/repos/o/r/branches/master", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "master"}`)
+	})
+	mux.HandleFunc("/repos/o/r/contents/charts/r/values.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"type": "file", "encoding": "base64", "content": "aW1hZ2U6CiAgdGFnOiB2MQo="}`)
+	})
+	mux.HandleFunc("/repos/o/r/contents/charts/r", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"type": "file", "name": "values.yaml", "path": "charts/r/values.yaml"}]`)
+	})
+	mux.HandleFunc("/repos/o/r/contents/charts/r/other.yaml", func(w http.ResponseWriter, r *http.Request) {
+		// replicas: 2
+		fmt.Fprint(w, `{"type": "file", "encoding": "base64", "content": "cmVwbGljYXM6IDIK"}`)
+	})
+
+	tests := []struct {
+		repo, file, branch string
+		expected           string
+	}{
+		{"r", "charts/r/values.yaml", "master", ""},
+		{"missing", "charts/r/values.yaml", "master", "repo o/missing: not found"},
+		{"r", "charts/r/values.yaml", "main", "branch main of o/r: not found"},
+		{"r", "charts/r/missing.yaml", "master", "file charts/r/missing.yaml on master of o/r: not found"},
+		{"r", "charts/r/other.yaml", "master", "file charts/r/other.yaml on master of o/r: " + ErrNoImageTag.Error()},
+		{"r", "charts/r", "master", "file charts/r on master of o/r: " + ErrNotAFile.Error()},
+	}
+	for _, test := range tests {
+		got := ""
+		if err := CheckManifest("o", test.repo, test.file, test.branch); err != nil {
+			got = err.Error()
+		}
+		if got != test.expected {
+			t.Errorf("expected: %q, got: %q", test.expected, got)
+		}
+	}
+}
+
+func TestReleaseBranchExists(t *testing.T) {
+	client, mux, cleanup := setup()
+	defer cleanup()
+	defer useClient(client)()
+
+	mux.HandleFunc("/repos/o/r/git/refs/heads/auto-release/master/o/r-v2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/auto-release/master/o/r-v2", "object": {"sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}}`)
+	})
+	// v1 is only a prefix of another branch
+	mux.HandleFunc("/repos/o/r/git/refs/heads/auto-release/master/o/r-v1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"ref": "refs/heads/auto-release/master/o/r-v1.1"}]`)
+	})
+	mux.HandleFunc("/repos/o/r/git/refs/heads/auto-release/master/o/r-v4", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		tag      string
+		expected bool
+		err      bool
+	}{
+		{"v2", true, false},
+		{"v1", false, false},
+		{"v3", false, false},
+		{"v4", false, true},
+	}
+	for _, test := range tests {
+		exists, err := ReleaseBranchExists("o", "r", "master", "o/r", test.tag)
+		if exists != test.expected || (err != nil) != test.err {
+			t.Errorf("%s | expected: %t (error: %t), got: %t (%v)", test.tag, test.expected, test.err, exists, err)
+		}
+	}
+}
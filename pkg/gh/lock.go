@@ -0,0 +1,23 @@
+package gh
+
+import "sync"
+
+var fileLocks = struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: map[string]*sync.Mutex{}}
+
+// lockFile blocks until no other update holds the lock for key.
+// The returned func releases the lock.
+func lockFile(key string) (unlock func()) {
+	fileLocks.Lock()
+	l, ok := fileLocks.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		fileLocks.locks[key] = l
+	}
+	fileLocks.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
@@ -0,0 +1,31 @@
+package gh
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	unlock := lockFile("o/r@master:values.yaml")
+
+	// A different file isn't blocked
+	lockFile("o/r@master:other.yaml")()
+
+	var wg sync.WaitGroup
+	locked := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lockFile("o/r@master:values.yaml")()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("expected the second lock to wait for the first one to be released")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	wg.Wait()
+}
@@ -18,3 +18,10 @@ func setup() (client *github.Client, mux *http.ServeMux, cleanup func()) {
 
 	return client, mux, server.Close
 }
+
+// useClient makes the package's functions that don't take a client use this one
+func useClient(client *github.Client) (restore func()) {
+	previous := _client
+	_client = client
+	return func() { _client = previous }
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import "time"
+
+const (
+	ACRImagePushed                  = "Microsoft.ContainerRegistry.ImagePushed"
+	EventGridSubscriptionValidation = "Microsoft.EventGrid.SubscriptionValidationEvent"
+)
+
+// ACR is the batch of Event Grid events received from Azure Container Registry
+// https://learn.microsoft.com/en-us/azure/event-grid/event-schema-container-registry
+type ACR []ACREvent
+
+type ACREvent struct {
+	ID          string       `json:"id"`
+	Topic       string       `json:"topic"`
+	Subject     string       `json:"subject"`
+	EventType   string       `json:"eventType"`
+	EventTime   time.Time    `json:"eventTime"`
+	DataVersion string       `json:"dataVersion"`
+	Data        ACREventData `json:"data"`
+}
+
+// ACREventData holds the data of both image pushed and subscription validation events
+type ACREventData struct {
+	ID        string                `json:"id"`
+	Timestamp time.Time             `json:"timestamp"`
+	Action    string                `json:"action"`
+	Target    DockerRegistryTarget  `json:"target"`
+	Request   DockerRegistryRequest `json:"request"`
+
+	// These are only sent with subscription validation events
+	ValidationCode string `json:"validationCode"`
+	ValidationURL  string `json:"validationUrl"`
+}
+
+// NameAndTag returns the first image that was pushed. See Images for all of them
+func (a *ACR) NameAndTag() (string, string) {
+	if images := a.Images(); len(images) > 0 {
+		return images[0].Name, images[0].Tag
+	}
+	return "", ""
+}
+
+// Images returns an Image for every tag that was pushed, which can be matched in
+// the manifest by either the repository, or the repository including the registry
+// like `myregistry.azurecr.io/repo`
+func (a *ACR) Images() []Image {
+	images := []Image{}
+	for _, e := range *a {
+		target := e.Data.Target
+		if e.EventType != ACRImagePushed || target.Tag == "" {
+			continue
+		}
+		image := Image{Name: target.Repository, Tag: target.Tag}
+		if host := e.Data.Request.Host; host != "" {
+			image.Aliases = []string{host + "/" + target.Repository}
+		}
+		images = append(images, image)
+	}
+	return images
+}
+
+// Response completes the Event Grid subscription validation handshake,
+// by echoing the validation code back
+func (a *ACR) Response() interface{} {
+	for _, e := range *a {
+		if e.EventType == EventGridSubscriptionValidation {
+			return map[string]string{"validationResponse": e.Data.ValidationCode}
+		}
+	}
+	return nil
+}
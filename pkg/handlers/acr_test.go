@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestACR_Images(t *testing.T) {
+	body := `[
+	  {
+	    "id": "831e1650-001e-001b-66ab-eeb76e069631",
+	    "topic": "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerRegistry/registries/myregistry",
+	    "subject": "team/app:v1.2.0",
+	    "eventType": "Microsoft.ContainerRegistry.ImagePushed",
+	    "eventTime": "2020-05-05T20:48:19.4556811Z",
+	    "data": {
+	      "id": "31c51664-e5bd-416a-a5df-e5206bc47ed0",
+	      "timestamp": "2020-05-05T20:48:19.1234567Z",
+	      "action": "push",
+	      "target": {
+	        "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+	        "size": 524,
+	        "digest": "sha256:xxxxd5c8786bb9e621a45ece0dbxxxx1cdc624ad20da9fe62e9d25490f33xxxx",
+	        "length": 524,
+	        "repository": "team/app",
+	        "tag": "v1.2.0"
+	      },
+	      "request": {"id": "9bbed8a8-4c35-41cb-8ed0-d1a1b0f7a4e0", "host": "myregistry.azurecr.io", "method": "PUT"}
+	    },
+	    "dataVersion": "1.0",
+	    "metadataVersion": "1"
+	  },
+	  {
+	    "id": "831e1650-001e-001b-66ab-eeb76e069632",
+	    "subject": "team/app:v1.1.0",
+	    "eventType": "Microsoft.ContainerRegistry.ImageDeleted",
+	    "eventTime": "2020-05-05T20:48:20Z",
+	    "data": {"action": "delete", "target": {"repository": "team/app", "tag": "v1.1.0"}}
+	  }
+	]`
+	var a ACR
+	if err := json.Unmarshal([]byte(body), &a); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{{Name: "team/app", Tag: "v1.2.0", Aliases: []string{"myregistry.azurecr.io/team/app"}}}
+	if got := a.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+	if name, tag := a.NameAndTag(); name != "team/app" || tag != "v1.2.0" {
+		t.Errorf("expected: team/app:v1.2.0, got: %s:%s", name, tag)
+	}
+	if resp := a.Response(); resp != nil {
+		t.Errorf("expected no response, got: %+v", resp)
+	}
+}
+
+func TestACR_Response(t *testing.T) {
+	body := `[{
+	  "id": "2d1781af-3a4c-4d7c-bd0c-e34b19da4e66",
+	  "topic": "/subscriptions/sub",
+	  "subject": "",
+	  "data": {
+	    "validationCode": "512d38b6-c7b8-40c8-89fe-f46f9e9622b6",
+	    "validationUrl": "https://rp-eastus2.eventgrid.azure.net:553/eventsubscriptions/blanche/validate?id=512d38b6"
+	  },
+	  "eventType": "Microsoft.EventGrid.SubscriptionValidationEvent",
+	  "eventTime": "2020-05-05T20:48:19.4556811Z",
+	  "metadataVersion": "1",
+	  "dataVersion": "1"
+	}]`
+	var a ACR
+	if err := json.Unmarshal([]byte(body), &a); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"validationResponse": "512d38b6-c7b8-40c8-89fe-f46f9e9622b6"}
+	if got := a.Response(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+	if images := a.Images(); len(images) != 0 {
+		t.Errorf("expected no images, got: %+v", images)
+	}
+}
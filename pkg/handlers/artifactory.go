@@ -0,0 +1,59 @@
+package handlers
+
+import "strings"
+
+// Artifactory is the body received from a JFrog Artifactory docker webhook
+// https://www.jfrog.com/confluence/display/JFROG/Webhooks
+type Artifactory struct {
+	Domain    string          `json:"domain"`
+	EventType string          `json:"event_type"`
+	Data      ArtifactoryData `json:"data"`
+}
+
+type ArtifactoryData struct {
+	RepoKey   string `json:"repo_key"`
+	EventType string `json:"event_type"`
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	Sha256    string `json:"sha256"`
+	Size      int    `json:"size"`
+	ImageName string `json:"image_name"`
+	Tag       string `json:"tag"`
+}
+
+// NameAndTag returns the image as `repoKey/image`
+func (a *Artifactory) NameAndTag() (string, string) {
+	return a.repoName(), a.Data.Tag
+}
+
+// Images returns the image that was pushed, which can be matched in the manifest
+// by either `repoKey/image` or just `image`. Events other than pushes are skipped.
+func (a *Artifactory) Images() []Image {
+	if !a.isPush() || a.Data.Tag == "" {
+		return []Image{}
+	}
+	image := Image{Name: a.repoName(), Tag: a.Data.Tag}
+	if a.Data.RepoKey != "" {
+		image.Aliases = []string{a.Data.ImageName}
+	}
+	return []Image{image}
+}
+
+func (a *Artifactory) isPush() bool {
+	eventType := a.EventType
+	if eventType == "" {
+		eventType = a.Data.EventType
+	}
+	switch strings.TrimPrefix(eventType, "docker.") {
+	case "pushed", "tagCreated":
+		return true
+	}
+	return false
+}
+
+func (a *Artifactory) repoName() string {
+	if a.Data.RepoKey == "" {
+		return a.Data.ImageName
+	}
+	return a.Data.RepoKey + "/" + a.Data.ImageName
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestArtifactory_Images(t *testing.T) {
+	body := `{
+	  "domain": "docker",
+	  "event_type": "pushed",
+	  "data": {
+	    "repo_key": "docker-local",
+	    "event_type": "pushed",
+	    "path": "myRepo/v1.2.0/manifest.json",
+	    "name": "manifest.json",
+	    "sha256": "fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+	    "size": 708,
+	    "image_name": "myRepo",
+	    "tag": "v1.2.0"
+	  },
+	  "subscription_key": "blanche",
+	  "source": "jfrog/user@example.com"
+	}`
+	var a Artifactory
+	if err := json.Unmarshal([]byte(body), &a); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{{Name: "docker-local/myRepo", Tag: "v1.2.0", Aliases: []string{"myRepo"}}}
+	if got := a.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestArtifactory_NameAndTag(t *testing.T) {
+	tests := []struct {
+		name, tag   string
+		artifactory Artifactory
+		images      []Image
+	}{
+		{"docker-local/myRepo", "v1", Artifactory{Data: ArtifactoryData{RepoKey: "docker-local", ImageName: "myRepo", Tag: "v1", EventType: "docker.tagCreated"}},
+			[]Image{{Name: "docker-local/myRepo", Tag: "v1", Aliases: []string{"myRepo"}}}},
+		{"myRepo", "v1", Artifactory{EventType: "pushed", Data: ArtifactoryData{ImageName: "myRepo", Tag: "v1"}},
+			[]Image{{Name: "myRepo", Tag: "v1"}}},
+		// Only pushes have tags to update
+		{"docker-local/myRepo", "v1", Artifactory{EventType: "deleted", Data: ArtifactoryData{RepoKey: "docker-local", ImageName: "myRepo", Tag: "v1"}},
+			[]Image{}},
+	}
+
+	for _, test := range tests {
+		name, tag := test.artifactory.NameAndTag()
+		if name != test.name {
+			t.Errorf("expected repo name: %s, got: %s", test.name, name)
+		}
+		if tag != test.tag {
+			t.Errorf("expected tag: %s, got: %s", test.tag, tag)
+		}
+		if images := test.artifactory.Images(); !reflect.DeepEqual(images, test.images) {
+			t.Errorf("expected: %+v, got: %+v", test.images, images)
+		}
+	}
+}
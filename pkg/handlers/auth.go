@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// TokenHeader is the header a shared secret token can be sent in,
+	// for registries that can't add it to the webhook URL
+	TokenHeader = "X-Blanche-Token"
+	// SignatureHeader is the header an HMAC-SHA256 signature of the body can be sent in,
+	// for systems that can sign their webhooks
+	SignatureHeader = "X-Blanche-Signature-256"
+)
+
+// authenticator returns true if the webhook was signed with, or contains, the secret
+type authenticator func(r *http.Request, body []byte, secret string) bool
+
+// webhookSecret is the shared secret configured for the registry type.
+// Webhooks for registry types without a secret are not authenticated.
+func webhookSecret(registryType string) string {
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, registryType)
+	return os.Getenv("WEBHOOK_SECRET_" + strings.ToUpper(key))
+}
+
+// tokenAuth checks for the secret in the `token` query parameter or TokenHeader
+func tokenAuth(r *http.Request, body []byte, secret string) bool {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = r.Header.Get(TokenHeader)
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// headerAuth checks that the header contains the secret, for registries
+// that let you configure a header to send with every webhook
+func headerAuth(header string) authenticator {
+	return func(r *http.Request, body []byte, secret string) bool {
+		value := r.Header.Get(header)
+		return value != "" && subtle.ConstantTimeCompare([]byte(value), []byte(secret)) == 1
+	}
+}
+
+// anyAuth succeeds if any of the authenticators succeed
+func anyAuth(authenticators ...authenticator) authenticator {
+	return func(r *http.Request, body []byte, secret string) bool {
+		for _, auth := range authenticators {
+			if auth(r, body, secret) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// signatureAuth checks for a hex encoded HMAC-SHA256 signature of the body in the given header.
+// The signature may be prefixed with `sha256=`, like GitHub's X-Hub-Signature-256.
+func signatureAuth(header string) authenticator {
+	return func(r *http.Request, body []byte, secret string) bool {
+		signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(header), "sha256="))
+		if err != nil || len(signature) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(signature, mac.Sum(nil))
+	}
+}
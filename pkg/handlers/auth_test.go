@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTokenAuth(t *testing.T) {
+	tests := []struct {
+		url, header string
+		expected    bool
+	}{
+		{"/webhook/dockerhub?token=s3cret", "", true},
+		{"/webhook/dockerhub", "s3cret", true},
+		{"/webhook/dockerhub?token=wrong", "", false},
+		{"/webhook/dockerhub", "wrong", false},
+		{"/webhook/dockerhub", "", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, test.url, nil)
+		if test.header != "" {
+			r.Header.Set(TokenHeader, test.header)
+		}
+		if got := tokenAuth(r, nil, "s3cret"); got != test.expected {
+			t.Errorf("%s %s | expected: %t, got: %t", test.url, test.header, test.expected, got)
+		}
+	}
+}
+
+func TestSignatureAuth(t *testing.T) {
+	body := []byte(`{"tag":"v1"}`)
+	tests := []struct {
+		signature string
+		expected  bool
+	}{
+		// echo -n '{"tag":"v1"}' | openssl dgst -sha256 -hmac s3cret
+		{"sha256=4fbeae9ad4c228327e5c8946051a3eb649f74bcb0a9d55a5f4a151b15fd290f3", true},
+		{"4fbeae9ad4c228327e5c8946051a3eb649f74bcb0a9d55a5f4a151b15fd290f3", true},
+		{"sha256=00beae9ad4c228327e5c8946051a3eb649f74bcb0a9d55a5f4a151b15fd290f3", false},
+		{"", false},
+		{"sha256=not-hex", false},
+	}
+	auth := signatureAuth("X-Hub-Signature-256")
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/webhook/ghcr", nil)
+		r.Header.Set("X-Hub-Signature-256", test.signature)
+		if got := auth(r, body, "s3cret"); got != test.expected {
+			t.Errorf("%s | expected: %t, got: %t", test.signature, test.expected, got)
+		}
+	}
+}
+
+func TestAnyAuth(t *testing.T) {
+	tests := []struct {
+		url, header string
+		expected    bool
+	}{
+		{"/webhook/harbor?token=s3cret", "", true},
+		{"/webhook/harbor", "s3cret", true},
+		{"/webhook/harbor", "Bearer s3cret", false},
+		{"/webhook/harbor", "", false},
+	}
+
+	auth := anyAuth(tokenAuth, headerAuth("Authorization"))
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, test.url, nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		if got := auth(r, nil, "s3cret"); got != test.expected {
+			t.Errorf("%s %s | expected: %t, got: %t", test.url, test.header, test.expected, got)
+		}
+	}
+}
+
+func TestWebhookSecret(t *testing.T) {
+	os.Setenv("WEBHOOK_SECRET_GENERIC_MY_CI", "s3cret")
+	defer os.Unsetenv("WEBHOOK_SECRET_GENERIC_MY_CI")
+
+	if got := webhookSecret("generic_my-ci"); got != "s3cret" {
+		t.Errorf("expected: %s, got: %s", "s3cret", got)
+	}
+	if got := webhookSecret("generic_other"); got != "" {
+		t.Errorf("expected no secret, got: %s", got)
+	}
+}
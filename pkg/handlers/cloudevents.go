@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RentTheRunway/blanche/pkg/cloudevents"
+)
+
+// CloudEvent is an image.pushed CloudEvent, received in either structured or binary mode
+// https://github.com/cloudevents/spec/blob/v1.0/cloudevents/bindings/http-protocol-binding.md
+type CloudEvent struct {
+	Event cloudevents.Event
+	Data  cloudevents.ImagePushedData
+}
+
+// Decode reads the event from the request, since binary mode events keep their attributes in headers
+func (c *CloudEvent) Decode(r *http.Request, body []byte) error {
+	e, err := cloudevents.FromRequest(r, body)
+	if err != nil {
+		return err
+	}
+	c.Event = e
+	// Events of other types are accepted, but aren't processed
+	if !e.IsType(cloudevents.ImagePushed) {
+		return nil
+	}
+	return json.Unmarshal(e.Data, &c.Data)
+}
+
+func (c *CloudEvent) NameAndTag() (string, string) {
+	if !c.Event.IsType(cloudevents.ImagePushed) {
+		return "", ""
+	}
+	return c.Data.Repository, c.Data.Tag
+}
+
+func (c *CloudEvent) Images() []Image {
+	name, tag := c.NameAndTag()
+	if tag == "" {
+		return []Image{}
+	}
+	return []Image{{Name: name, Tag: tag, Digest: c.Data.Digest}}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/queue"
+)
+
+func TestCloudEvent(t *testing.T) {
+	var got []queue.Job
+	jobs := queue.New(1, 10, nil, func(job queue.Job) { got = append(got, job) })
+	r := newRouter(jobs)
+
+	binary := func(eventType, specVersion string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/cloudevents", strings.NewReader(`{"repository":"o/r","tag":"v1","digest":"sha256:abc"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("ce-specversion", specVersion)
+		req.Header.Set("ce-id", "1")
+		req.Header.Set("ce-source", "https://ci.example.com")
+		req.Header.Set("ce-type", eventType)
+		return req
+	}
+	structured := httptest.NewRequest(http.MethodPost, "/webhook/cloudevents", strings.NewReader(`{
+	  "specversion": "1.0",
+	  "id": "2",
+	  "source": "https://ci.example.com",
+	  "type": "image.pushed",
+	  "data": {"repository": "o/r", "tag": "v2"}
+	}`))
+	structured.Header.Set("Content-Type", "application/cloudevents+json")
+
+	tests := []struct {
+		r        *http.Request
+		expected int
+	}{
+		{binary("com.example.image.pushed", "1.0"), http.StatusOK},
+		{structured, http.StatusOK},
+		// Other types of events are ignored
+		{binary("com.example.build.finished", "1.0"), http.StatusOK},
+		{binary("image.pushed", "0.3"), http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, test.r)
+		if w.Code != test.expected {
+			t.Errorf("%s | expected status: %d, got: %d", test.r.Header.Get("ce-type"), test.expected, w.Code)
+		}
+	}
+
+	jobs.Start()
+	jobs.Stop()
+	expected := []queue.Job{{Name: "o/r", Tag: "v1"}, {Name: "o/r", Tag: "v2"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"github.com/RentTheRunway/blanche/pkg/store"
+	"github.com/gorilla/mux"
+)
+
+// DeadLettersHandler returns a handler that lists every event with updates that ran out of retries
+func DeadLettersHandler(events *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadLetters := events.DeadLetters()
+		if deadLetters == nil {
+			deadLetters = []store.Event{}
+		}
+		json.NewEncoder(w).Encode(deadLetters)
+	}
+}
+
+// DeadLetterHandler returns a handler that shows a single event, including all of its updates
+func DeadLetterHandler(events *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event, ok := events.Get(mux.Vars(r)["id"])
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(event)
+	}
+}
+
+// RedriveHandler returns a handler that queues an event's dead letter updates to be attempted again
+func RedriveHandler(jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event, err := jobs.Redrive(mux.Vars(r)["id"])
+		switch err {
+		case nil:
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(event)
+		case queue.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case queue.ErrInFlight:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
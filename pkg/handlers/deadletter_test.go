@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"github.com/RentTheRunway/blanche/pkg/store"
+	"github.com/gorilla/mux"
+)
+
+func TestDeadLetterHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blanche-handlers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	events, err := store.Open(filepath.Join(dir, "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := queue.New(1, 1, events, func(queue.Job) {})
+
+	dead, _ := events.Add(store.Event{Name: "o/r", Tag: "v1"})
+	dead.Done = true
+	dead.Updates = []store.Update{{Status: store.StatusDeadLetter, Attempts: 5}}
+	events.Save(dead)
+	done, _ := events.Add(store.Event{Name: "o/r", Tag: "v2"})
+	done.Done = true
+	events.Save(done)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/deadletters", DeadLettersHandler(events)).Methods(http.MethodGet)
+	r.HandleFunc("/deadletters/{id}", DeadLetterHandler(events)).Methods(http.MethodGet)
+	r.HandleFunc("/deadletters/{id}/redrive", RedriveHandler(jobs)).Methods(http.MethodPost)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/deadletters", nil))
+	var list []store.Event
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Error(err)
+	}
+	if len(list) != 1 || list[0].ID != dead.ID {
+		t.Errorf("expected only event %s, got: %+v", dead.ID, list)
+	}
+
+	tests := []struct {
+		method, path string
+		expected     int
+	}{
+		{http.MethodGet, "/deadletters/" + dead.ID, http.StatusOK},
+		{http.MethodGet, "/deadletters/missing", http.StatusNotFound},
+		{http.MethodPost, "/deadletters/missing/redrive", http.StatusNotFound},
+		{http.MethodPost, "/deadletters/" + dead.ID + "/redrive", http.StatusAccepted},
+		// It is now queued, and can't be redriven until it has been processed
+		{http.MethodPost, "/deadletters/" + dead.ID + "/redrive", http.StatusConflict},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.expected {
+			t.Errorf("%s %s | expected status: %d, got: %d", test.method, test.path, test.expected, w.Code)
+		}
+	}
+
+	if got, _ := events.Get(dead.ID); got.Done || got.Updates[0].Status != store.StatusPending {
+		t.Errorf("expected event to be redriven, got: %+v", got)
+	}
+}
@@ -36,3 +36,8 @@ type DockerhubRepository struct {
 func (dh *Dockerhub) NameAndTag() (string, string) {
 	return dh.Repository.RepoName, dh.PushData.Tag
 }
+
+// Callback is the URL where Docker Hub expects the outcome of the webhook to be reported
+func (dh *Dockerhub) Callback() string {
+	return dh.CallbackURL
+}
@@ -27,3 +27,10 @@ func TestDockerhub_NameAndTag(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerhub_Callback(t *testing.T) {
+	dh := Dockerhub{CallbackURL: "https://registry.hub.docker.com/u/o/r/hook/1/"}
+	if got := dh.Callback(); got != dh.CallbackURL {
+		t.Errorf("expected callback: %s, got: %s", dh.CallbackURL, got)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import "fmt"
+
+// ECR is an Amazon ECR "ECR Image Action" EventBridge event,
+// delivered by an EventBridge API destination
+// https://docs.aws.amazon.com/AmazonECR/latest/userguide/ecr-eventbridge.html
+type ECR struct {
+	Version    string    `json:"version"`
+	ID         string    `json:"id"`
+	DetailType string    `json:"detail-type"`
+	Source     string    `json:"source"`
+	Account    string    `json:"account"`
+	Time       string    `json:"time"`
+	Region     string    `json:"region"`
+	Detail     ECRDetail `json:"detail"`
+}
+
+type ECRDetail struct {
+	Result         string `json:"result"`
+	RepositoryName string `json:"repository-name"`
+	ImageDigest    string `json:"image-digest"`
+	ActionType     string `json:"action-type"`
+	ImageTag       string `json:"image-tag"`
+}
+
+// NameAndTag returns the image including its registry,
+// like `123456789012.dkr.ecr.us-east-1.amazonaws.com/repo`
+func (e *ECR) NameAndTag() (string, string) {
+	if e.Account == "" || e.Region == "" {
+		return e.Detail.RepositoryName, e.Detail.ImageTag
+	}
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s", e.Account, e.Region, e.Detail.RepositoryName), e.Detail.ImageTag
+}
+
+// Images returns the image that was pushed, which can be matched in the manifest
+// by either its name including the registry, or just the repository name.
+// Anything other than a successful, tagged push is skipped.
+func (e *ECR) Images() []Image {
+	d := e.Detail
+	if e.DetailType != "ECR Image Action" || d.ActionType != "PUSH" || d.Result != "SUCCESS" || d.ImageTag == "" {
+		return []Image{}
+	}
+	name, tag := e.NameAndTag()
+	image := Image{Name: name, Tag: tag}
+	if name != d.RepositoryName {
+		image.Aliases = []string{d.RepositoryName}
+	}
+	return []Image{image}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestECR_Images(t *testing.T) {
+	event := func(actionType, result, tag string) string {
+		return `{
+		  "version": "0",
+		  "id": "13cde686-328b-6117-af20-0e5566167482",
+		  "detail-type": "ECR Image Action",
+		  "source": "aws.ecr",
+		  "account": "123456789012",
+		  "time": "2020-05-05T20:48:19Z",
+		  "region": "us-east-1",
+		  "resources": [],
+		  "detail": {
+		    "result": "` + result + `",
+		    "repository-name": "team/app",
+		    "image-digest": "sha256:7f5b2640fe6fb4f46592dfd3410c4a79dac4f89e4782432e0378abcd1234",
+		    "action-type": "` + actionType + `",
+		    "image-tag": "` + tag + `"
+		  }
+		}`
+	}
+	tests := []struct {
+		body     string
+		expected []Image
+	}{
+		{event("PUSH", "SUCCESS", "v1.2.0"), []Image{{
+			Name:    "123456789012.dkr.ecr.us-east-1.amazonaws.com/team/app",
+			Tag:     "v1.2.0",
+			Aliases: []string{"team/app"},
+		}}},
+		{event("PUSH", "FAILURE", "v1.2.0"), []Image{}},
+		{event("DELETE", "SUCCESS", "v1.2.0"), []Image{}},
+		{event("PUSH", "SUCCESS", ""), []Image{}},
+	}
+
+	for _, test := range tests {
+		var e ECR
+		if err := json.Unmarshal([]byte(test.body), &e); err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Images(); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected: %+v, got: %+v", test.expected, got)
+		}
+	}
+}
+
+func TestECR_NameAndTag(t *testing.T) {
+	tests := []struct {
+		name, tag string
+		ecr       ECR
+	}{
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com/app", "v1", ECR{Account: "123456789012", Region: "eu-west-1", Detail: ECRDetail{RepositoryName: "app", ImageTag: "v1"}}},
+		{"app", "v1", ECR{Detail: ECRDetail{RepositoryName: "app", ImageTag: "v1"}}},
+	}
+
+	for _, test := range tests {
+		name, tag := test.ecr.NameAndTag()
+		if name != test.name {
+			t.Errorf("expected repo name: %s, got: %s", test.name, name)
+		}
+		if tag != test.tag {
+			t.Errorf("expected tag: %s, got: %s", test.tag, tag)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// GCR is the body received from a Pub/Sub push subscription to the `gcr` topic,
+// which Google Container Registry and Artifact Registry publish to
+// https://cloud.google.com/artifact-registry/docs/configure-notifications
+type GCR struct {
+	Message      GCRMessage `json:"message"`
+	Subscription string     `json:"subscription"`
+
+	// Notification is decoded from Message.Data
+	Notification GCRNotification `json:"-"`
+}
+
+type GCRMessage struct {
+	// Data is base64 encoded JSON, which is decoded by encoding/json
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes"`
+	MessageID   string            `json:"messageId"`
+	PublishTime string            `json:"publishTime"`
+}
+
+type GCRNotification struct {
+	Action string `json:"action"`
+	Digest string `json:"digest"`
+	Tag    string `json:"tag"`
+}
+
+func (g *GCR) UnmarshalJSON(data []byte) error {
+	type envelope GCR
+	if err := json.Unmarshal(data, (*envelope)(g)); err != nil {
+		return err
+	}
+	if len(g.Message.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(g.Message.Data, &g.Notification)
+}
+
+// NameAndTag returns the image as `host/path`
+func (g *GCR) NameAndTag() (string, string) {
+	host, path, tag := splitImageReference(g.Notification.Tag)
+	if host == "" {
+		return path, tag
+	}
+	return host + "/" + path, tag
+}
+
+// Images returns the image that was pushed, which can be matched in the manifest
+// by either `host/path` or just `path`. Deletes and untagged pushes are skipped.
+func (g *GCR) Images() []Image {
+	if g.Notification.Action != "INSERT" {
+		return []Image{}
+	}
+	_, path, tag := splitImageReference(g.Notification.Tag)
+	if tag == "" {
+		return []Image{}
+	}
+	name, _ := g.NameAndTag()
+	return []Image{{Name: name, Tag: tag, Aliases: []string{path}}}
+}
+
+// splitImageReference splits an image reference like `us-docker.pkg.dev/proj/repo/img:v1.2.3`
+// into its registry host, path and tag
+func splitImageReference(ref string) (host, path, tag string) {
+	path = ref
+	// The tag comes after the last colon, as long as it is in the last path component,
+	// since the host may include a port
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		path, tag = path[:i], path[i+1:]
+	}
+	if i := strings.Index(path, "/"); i >= 0 {
+		// Like docker, the first component is only a host if it looks like one
+		if first := path[:i]; strings.ContainsAny(first, ".:") || first == "localhost" {
+			host, path = first, path[i+1:]
+		}
+	}
+	return host, path, tag
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func gcrBody(data string) string {
+	return `{
+	  "message": {
+	    "data": "` + base64.StdEncoding.EncodeToString([]byte(data)) + `",
+	    "messageId": "2070443601311540",
+	    "publishTime": "2020-05-05T20:48:19.123Z"
+	  },
+	  "subscription": "projects/proj/subscriptions/blanche"
+	}`
+}
+
+func TestGCR_Images(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected []Image
+	}{
+		{`{"action":"INSERT","digest":"us-docker.pkg.dev/proj/repo/img@sha256:6ec128e26cd5","tag":"us-docker.pkg.dev/proj/repo/img:v1.2.3"}`,
+			[]Image{{Name: "us-docker.pkg.dev/proj/repo/img", Tag: "v1.2.3", Aliases: []string{"proj/repo/img"}}}},
+		{`{"action":"INSERT","digest":"gcr.io/proj/img@sha256:6ec128e26cd5","tag":"gcr.io/proj/img:v2"}`,
+			[]Image{{Name: "gcr.io/proj/img", Tag: "v2", Aliases: []string{"proj/img"}}}},
+		// Untagged pushes
+		{`{"action":"INSERT","digest":"gcr.io/proj/img@sha256:6ec128e26cd5"}`, []Image{}},
+		{`{"action":"DELETE","tag":"gcr.io/proj/img:v2"}`, []Image{}},
+	}
+
+	for _, test := range tests {
+		var g GCR
+		if err := json.Unmarshal([]byte(gcrBody(test.data)), &g); err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Images(); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s | expected: %+v, got: %+v", test.data, test.expected, got)
+		}
+	}
+
+	var g GCR
+	if err := json.Unmarshal([]byte(gcrBody("not json")), &g); err == nil {
+		t.Error("should have returned an error, but got nil")
+	}
+}
+
+func TestGCR_NameAndTag(t *testing.T) {
+	g := GCR{Notification: GCRNotification{Action: "INSERT", Tag: "us-docker.pkg.dev/proj/repo/img:v1"}}
+	if name, tag := g.NameAndTag(); name != "us-docker.pkg.dev/proj/repo/img" || tag != "v1" {
+		t.Errorf("expected: us-docker.pkg.dev/proj/repo/img:v1, got: %s:%s", name, tag)
+	}
+}
+
+func TestSplitImageReference(t *testing.T) {
+	tests := []struct {
+		ref, host, path, tag string
+	}{
+		{"us-docker.pkg.dev/proj/repo/img:v1.2.3", "us-docker.pkg.dev", "proj/repo/img", "v1.2.3"},
+		{"localhost:5000/img:v1", "localhost:5000", "img", "v1"},
+		{"localhost:5000/img", "localhost:5000", "img", ""},
+		{"myOrg/myRepo:v1", "", "myOrg/myRepo", "v1"},
+		{"myRepo", "", "myRepo", ""},
+	}
+
+	for _, test := range tests {
+		host, path, tag := splitImageReference(test.ref)
+		if host != test.host || path != test.path || tag != test.tag {
+			t.Errorf("splitImageReference(%s) | expected: %s %s %s, got: %s %s %s", test.ref, test.host, test.path, test.tag, host, path, tag)
+		}
+	}
+}
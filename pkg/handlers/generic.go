@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+)
+
+// Generic is the body of a webhook from any system, mapped to a docker image tag
+// by the paths configured in its config.GenericWebhook
+type Generic struct {
+	config config.GenericWebhook
+	body   interface{}
+}
+
+func newGeneric(name string) DockerRegistryHandler {
+	gw := config.GetGenericWebhook(name)
+	if gw == nil {
+		return nil
+	}
+	return &Generic{config: *gw}
+}
+
+func (g *Generic) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &g.body)
+}
+
+func (g *Generic) NameAndTag() (string, string) {
+	name, _ := lookupPath(g.body, g.config.Repository)
+	tag, _ := lookupPath(g.body, g.config.Tag)
+	return name, tag
+}
+
+// Images returns the image, unless the body doesn't have both a repository and a tag
+func (g *Generic) Images() []Image {
+	name, tag := g.NameAndTag()
+	if name == "" || tag == "" {
+		return []Image{}
+	}
+	digest, _ := lookupPath(g.body, g.config.Digest)
+	return []Image{{Name: name, Tag: tag, Digest: digest}}
+}
+
+// lookupPath returns the value at the path in data decoded from JSON. Paths can be
+// written like JSONPath, `$.resources[0].tag`, or like gjson, `resources.0.tag`.
+// Only strings, numbers and booleans are returned.
+func lookupPath(data interface{}, path string) (string, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return "", false
+	}
+
+	value := data
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			value = v[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+)
+
+func TestGeneric_Images(t *testing.T) {
+	body := `{
+	  "build": {
+	    "number": 42,
+	    "passed": true,
+	    "meta_data": {"image": "myOrg/myRepo", "tags": ["v1.2.0", "latest"]}
+	  },
+	  "image": {"name": "myOrg/other", "tag": "v2", "digest": "sha256:fea8895f450959fa"}
+	}`
+	tests := []struct {
+		webhook  config.GenericWebhook
+		expected []Image
+	}{
+		{config.GenericWebhook{Repository: "$.image.name", Tag: "$.image.tag", Digest: "$.image.digest"},
+			[]Image{{Name: "myOrg/other", Tag: "v2", Digest: "sha256:fea8895f450959fa"}}},
+		{config.GenericWebhook{Repository: "build.meta_data.image", Tag: "build.meta_data.tags[0]"},
+			[]Image{{Name: "myOrg/myRepo", Tag: "v1.2.0"}}},
+		{config.GenericWebhook{Repository: "build.meta_data.image", Tag: "build.meta_data.tags.1"},
+			[]Image{{Name: "myOrg/myRepo", Tag: "latest"}}},
+		{config.GenericWebhook{Repository: "build.meta_data.image", Tag: "build.number"},
+			[]Image{{Name: "myOrg/myRepo", Tag: "42"}}},
+		{config.GenericWebhook{Repository: "build.meta_data.image", Tag: "build.meta_data.missing"}, []Image{}},
+	}
+
+	for _, test := range tests {
+		g := Generic{config: test.webhook}
+		if err := json.Unmarshal([]byte(body), &g); err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Images(); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%+v | expected: %+v, got: %+v", test.webhook, test.expected, got)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{"a": {"b": [{"c": "d"}, 1.5, false, null, {"e": "f"}]}}`), &data)
+
+	tests := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{"$.a.b[0].c", "d", true},
+		{"a.b.0.c", "d", true},
+		{"a.b[1]", "1.5", true},
+		{"a.b[2]", "false", true},
+		{"a.b[3]", "", false},
+		{"a.b[4]", "", false},
+		{"a.b[5].c", "", false},
+		{"a.b.x", "", false},
+		{"a.missing.c", "", false},
+		{"", "", false},
+		{"$", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := lookupPath(data, test.path)
+		if got != test.expected || ok != test.ok {
+			t.Errorf("lookupPath(%s) | expected: %s %t, got: %s %t", test.path, test.expected, test.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import "strings"
+
+// GHCR is the body of a GitHub `package` or `registry_package` webhook event,
+// sent when a container image is published to the GitHub Container Registry
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads#package
+type GHCR struct {
+	Action          string       `json:"action"`
+	Package         *GHCRPackage `json:"package"`
+	RegistryPackage *GHCRPackage `json:"registry_package"`
+}
+
+type GHCRPackage struct {
+	Name           string             `json:"name"`
+	Namespace      string             `json:"namespace"`
+	PackageType    string             `json:"package_type"`
+	Owner          GHCROwner          `json:"owner"`
+	PackageVersion GHCRPackageVersion `json:"package_version"`
+}
+
+type GHCROwner struct {
+	Login string `json:"login"`
+}
+
+type GHCRPackageVersion struct {
+	Version           string                `json:"version"`
+	ContainerMetadata GHCRContainerMetadata `json:"container_metadata"`
+}
+
+type GHCRContainerMetadata struct {
+	Tag GHCRTag `json:"tag"`
+}
+
+type GHCRTag struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+// NameAndTag returns the package as `owner/package`
+func (g *GHCR) NameAndTag() (string, string) {
+	pkg := g.pkg()
+	if pkg == nil {
+		return "", ""
+	}
+	return pkg.Owner.Login + "/" + pkg.Name, pkg.PackageVersion.ContainerMetadata.Tag.Name
+}
+
+// Images returns the container image that was published, which can be matched in
+// the manifest by either `owner/package` or `ghcr.io/owner/package`.
+// Other events, such as pings and untagged versions, are skipped.
+func (g *GHCR) Images() []Image {
+	pkg := g.pkg()
+	if pkg == nil || !strings.EqualFold(pkg.PackageType, "container") {
+		return []Image{}
+	}
+	switch g.Action {
+	case "published", "updated":
+	default:
+		return []Image{}
+	}
+
+	name, tag := g.NameAndTag()
+	if tag == "" {
+		return []Image{}
+	}
+	return []Image{{Name: name, Tag: tag, Aliases: []string{"ghcr.io/" + name}}}
+}
+
+// pkg returns the package from either type of event
+func (g *GHCR) pkg() *GHCRPackage {
+	if g.Package != nil {
+		return g.Package
+	}
+	return g.RegistryPackage
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGHCR_Images(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []Image
+	}{
+		{`{
+		  "action": "published",
+		  "package": {
+		    "id": 1,
+		    "name": "myRepo",
+		    "namespace": "myOrg",
+		    "package_type": "CONTAINER",
+		    "owner": {"login": "myOrg"},
+		    "package_version": {
+		      "version": "sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+		      "container_metadata": {
+		        "tag": {"name": "v1.2.0", "digest": "sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf"}
+		      }
+		    }
+		  }
+		}`, []Image{{Name: "myOrg/myRepo", Tag: "v1.2.0", Aliases: []string{"ghcr.io/myOrg/myRepo"}}}},
+		{`{
+		  "action": "published",
+		  "registry_package": {
+		    "name": "myRepo",
+		    "package_type": "container",
+		    "owner": {"login": "myOrg"},
+		    "package_version": {"container_metadata": {"tag": {"name": "v1.3.0"}}}
+		  }
+		}`, []Image{{Name: "myOrg/myRepo", Tag: "v1.3.0", Aliases: []string{"ghcr.io/myOrg/myRepo"}}}},
+		// Untagged versions
+		{`{
+		  "action": "published",
+		  "package": {
+		    "name": "myRepo",
+		    "package_type": "CONTAINER",
+		    "owner": {"login": "myOrg"},
+		    "package_version": {"container_metadata": {"tag": {"name": ""}}}
+		  }
+		}`, []Image{}},
+		// Other package types
+		{`{
+		  "action": "published",
+		  "package": {
+		    "name": "myLib",
+		    "package_type": "npm",
+		    "owner": {"login": "myOrg"},
+		    "package_version": {"version": "1.0.0"}
+		  }
+		}`, []Image{}},
+		// GitHub sends a ping when the webhook is created
+		{`{"zen": "Keep it logically awesome.", "hook_id": 1}`, []Image{}},
+	}
+
+	for _, test := range tests {
+		var g GHCR
+		if err := json.Unmarshal([]byte(test.body), &g); err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Images(); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected: %+v, got: %+v", test.expected, got)
+		}
+	}
+}
+
+func TestGHCR_NameAndTag(t *testing.T) {
+	g := GHCR{Package: &GHCRPackage{
+		Name:           "myRepo",
+		Owner:          GHCROwner{Login: "myOrg"},
+		PackageVersion: GHCRPackageVersion{ContainerMetadata: GHCRContainerMetadata{Tag: GHCRTag{Name: "v1"}}},
+	}}
+	if name, tag := g.NameAndTag(); name != "myOrg/myRepo" || tag != "v1" {
+		t.Errorf("expected: myOrg/myRepo:v1, got: %s:%s", name, tag)
+	}
+	if name, tag := (&GHCR{}).NameAndTag(); name != "" || tag != "" {
+		t.Errorf("expected no name or tag, got: %s:%s", name, tag)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import "os"
+
+const (
+	// DefaultGitlabImageNameVariable is the pipeline variable containing the docker image name,
+	// unless it is set by GITLAB_IMAGE_NAME_VARIABLE
+	DefaultGitlabImageNameVariable = "BLANCHE_IMAGE_NAME"
+	// DefaultGitlabImageTagVariable is the pipeline variable containing the docker image tag,
+	// unless it is set by GITLAB_IMAGE_TAG_VARIABLE
+	DefaultGitlabImageTagVariable = "BLANCHE_IMAGE_TAG"
+)
+
+// Gitlab is the body received from a GitLab pipeline or job webhook
+// https://docs.gitlab.com/ee/user/project/integrations/webhook_events.html
+type Gitlab struct {
+	ObjectKind       string                 `json:"object_kind"`
+	ObjectAttributes GitlabObjectAttributes `json:"object_attributes"`
+	Project          GitlabProject          `json:"project"`
+
+	// These are only sent with job events
+	Ref         string `json:"ref"`
+	Tag         bool   `json:"tag"`
+	BuildName   string `json:"build_name"`
+	BuildStatus string `json:"build_status"`
+}
+
+type GitlabObjectAttributes struct {
+	ID        int              `json:"id"`
+	Ref       string           `json:"ref"`
+	Tag       bool             `json:"tag"`
+	Sha       string           `json:"sha"`
+	Status    string           `json:"status"`
+	Variables []GitlabVariable `json:"variables"`
+}
+
+type GitlabVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type GitlabProject struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+// NameAndTag returns the image name and tag from the pipeline variables.
+// Without the variables, the image name is the project's path, and the tag
+// is the git tag the pipeline ran for.
+func (g *Gitlab) NameAndTag() (string, string) {
+	name := g.variable(getEnv("GITLAB_IMAGE_NAME_VARIABLE", DefaultGitlabImageNameVariable))
+	if name == "" {
+		name = g.Project.PathWithNamespace
+	}
+
+	tag := g.variable(getEnv("GITLAB_IMAGE_TAG_VARIABLE", DefaultGitlabImageTagVariable))
+	if tag == "" {
+		switch {
+		case g.ObjectKind == "pipeline" && g.ObjectAttributes.Tag:
+			tag = g.ObjectAttributes.Ref
+		case g.ObjectKind == "build" && g.Tag:
+			tag = g.Ref
+		}
+	}
+	return name, tag
+}
+
+// Images returns the image built by a successful pipeline or job
+func (g *Gitlab) Images() []Image {
+	status := g.ObjectAttributes.Status
+	if g.ObjectKind == "build" {
+		status = g.BuildStatus
+	}
+	name, tag := g.NameAndTag()
+	if status != "success" || name == "" || tag == "" {
+		return []Image{}
+	}
+	return []Image{{Name: name, Tag: tag}}
+}
+
+// variable returns the value of the pipeline variable. Job events don't include variables
+func (g *Gitlab) variable(key string) string {
+	for _, v := range g.ObjectAttributes.Variables {
+		if v.Key == key {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+func getEnv(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
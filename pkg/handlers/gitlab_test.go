@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGitlab_Images(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []Image
+	}{
+		{`{
+		  "object_kind": "pipeline",
+		  "object_attributes": {
+		    "id": 31,
+		    "ref": "master",
+		    "tag": false,
+		    "sha": "bcbb5ec396a2c0f828686f14fac9b80b780504f2",
+		    "status": "success",
+		    "variables": [
+		      {"key": "BLANCHE_IMAGE_NAME", "value": "registry.gitlab.com/myGroup/myRepo"},
+		      {"key": "BLANCHE_IMAGE_TAG", "value": "v1.2.0"}
+		    ]
+		  },
+		  "project": {"id": 1, "name": "myRepo", "path_with_namespace": "myGroup/myRepo"}
+		}`, []Image{{Name: "registry.gitlab.com/myGroup/myRepo", Tag: "v1.2.0"}}},
+		// Without the variables, tag pipelines use the project path and git tag
+		{`{
+		  "object_kind": "pipeline",
+		  "object_attributes": {"ref": "v1.3.0", "tag": true, "status": "success"},
+		  "project": {"path_with_namespace": "myGroup/myRepo"}
+		}`, []Image{{Name: "myGroup/myRepo", Tag: "v1.3.0"}}},
+		{`{
+		  "object_kind": "build",
+		  "ref": "v1.4.0",
+		  "tag": true,
+		  "build_name": "docker-build",
+		  "build_status": "success",
+		  "project": {"path_with_namespace": "myGroup/myRepo"}
+		}`, []Image{{Name: "myGroup/myRepo", Tag: "v1.4.0"}}},
+		// Unsuccessful pipelines
+		{`{
+		  "object_kind": "pipeline",
+		  "object_attributes": {"ref": "v1.3.0", "tag": true, "status": "failed"},
+		  "project": {"path_with_namespace": "myGroup/myRepo"}
+		}`, []Image{}},
+		// Branch pipelines without a tag variable
+		{`{
+		  "object_kind": "pipeline",
+		  "object_attributes": {"ref": "master", "tag": false, "status": "success"},
+		  "project": {"path_with_namespace": "myGroup/myRepo"}
+		}`, []Image{}},
+	}
+
+	for _, test := range tests {
+		var g Gitlab
+		if err := json.Unmarshal([]byte(test.body), &g); err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Images(); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected: %+v, got: %+v", test.expected, got)
+		}
+	}
+}
+
+func TestGitlab_NameAndTagVariables(t *testing.T) {
+	os.Setenv("GITLAB_IMAGE_NAME_VARIABLE", "IMAGE")
+	os.Setenv("GITLAB_IMAGE_TAG_VARIABLE", "VERSION")
+	defer os.Unsetenv("GITLAB_IMAGE_NAME_VARIABLE")
+	defer os.Unsetenv("GITLAB_IMAGE_TAG_VARIABLE")
+
+	g := Gitlab{
+		ObjectKind: "pipeline",
+		ObjectAttributes: GitlabObjectAttributes{Variables: []GitlabVariable{
+			{Key: "BLANCHE_IMAGE_NAME", Value: "ignored"},
+			{Key: "IMAGE", Value: "myGroup/myRepo"},
+			{Key: "VERSION", Value: "v2"},
+		}},
+	}
+	if name, tag := g.NameAndTag(); name != "myGroup/myRepo" || tag != "v2" {
+		t.Errorf("expected: myGroup/myRepo:v2, got: %s:%s", name, tag)
+	}
+}
@@ -2,38 +2,250 @@ package handlers
 
 import (
 	"encoding/json"
+	"expvar"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 
 	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/queue"
 	"github.com/gorilla/mux"
 )
 
+// maxBodySize limits how much of a webhook's body is read
+const maxBodySize = 1 << 20
+
+var (
+	webhooksReceived     = expvar.NewMap("webhooks_received")
+	webhooksUnauthorized = expvar.NewMap("webhooks_unauthorized")
+)
+
 type DockerRegistryHandler interface {
 	NameAndTag() (name string, tag string)
 }
 
-func DockerHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	registryType := vars["type"]
+// Image is a docker image tag that was pushed
+type Image struct {
+	Name string
+	Tag  string
+	// Aliases are other names the image can be configured as in the manifest
+	Aliases []string
+	// Digest is only included for registries that send it
+	Digest string
+}
+
+// resolveName returns the first of the image's names that has a matching manifest
+func (i Image) resolveName() string {
+	if len(i.Aliases) == 0 {
+		return i.Name
+	}
+	for _, name := range append([]string{i.Name}, i.Aliases...) {
+		if config.GetManifest(name) != nil {
+			return name
+		}
+	}
+	return i.Name
+}
 
-	var dockerHandler DockerRegistryHandler
-	switch registryType {
-	case "dockerhub":
-		dockerHandler = new(Dockerhub)
-		// case "artifactory":
-		// 	dockerHandler := Artifactory{}
+// otherNames returns the image's names other than name, whose manifests are merged with its manifest
+func (i Image) otherNames(name string) []string {
+	var names []string
+	for _, n := range append([]string{i.Name}, i.Aliases...) {
+		if n != name {
+			names = append(names, n)
+		}
 	}
-	if err := json.NewDecoder(r.Body).Decode(dockerHandler); err != nil {
-		log.Println(err)
-		return
+	return names
+}
+
+// multiImageHandler is implemented by registries that can send
+// more than one image tag in a single webhook
+type multiImageHandler interface {
+	DockerRegistryHandler
+	Images() []Image
+}
+
+// callbackHandler is implemented by registries that expect the outcome
+// of a webhook to be reported back to them
+type callbackHandler interface {
+	Callback() string
+}
+
+// responder is implemented by registries that expect a specific response to some
+// webhooks, like a subscription handshake. When Response returns something other
+// than nil, it is sent as the response and the webhook isn't processed any further.
+type responder interface {
+	Response() interface{}
+}
+
+// requestDecoder is implemented by registries that need more than the body to decode a
+// webhook, like CloudEvents in binary mode. It's used instead of unmarshaling the body.
+type requestDecoder interface {
+	Decode(r *http.Request, body []byte) error
+}
+
+// registry describes how to handle webhooks from a type of docker registry
+type registry struct {
+	new func() DockerRegistryHandler
+	// newNamed is used instead of new for registries with an endpoint per name, like /webhook/generic/{name}.
+	// It returns nil when nothing is configured for the name.
+	newNamed func(name string) DockerRegistryHandler
+	// auth is used to authenticate webhooks when a secret is configured for the registry type
+	auth authenticator
+}
+
+var registries = map[string]registry{
+	"acr": {
+		new: func() DockerRegistryHandler { return new(ACR) },
+		// Event Grid can only send the secret in the webhook's URL
+		auth: tokenAuth,
+	},
+	"artifactory": {
+		new: func() DockerRegistryHandler { return new(Artifactory) },
+		// Artifactory sends the webhook's "Secret token" in X-JFrog-Event-Auth
+		auth: anyAuth(tokenAuth, headerAuth("X-JFrog-Event-Auth")),
+	},
+	"cloudevents": {
+		new:  func() DockerRegistryHandler { return new(CloudEvent) },
+		auth: anyAuth(tokenAuth, signatureAuth(SignatureHeader)),
+	},
+	"dockerhub": {
+		new:  func() DockerRegistryHandler { return new(Dockerhub) },
+		auth: tokenAuth,
+	},
+	"ecr": {
+		new: func() DockerRegistryHandler { return new(ECR) },
+		// Use an API key authorization for the API destination's connection, with X-Blanche-Token as the key name
+		auth: tokenAuth,
+	},
+	"gcr": {
+		new: func() DockerRegistryHandler { return new(GCR) },
+		// Pub/Sub can only send the secret in the push endpoint's URL
+		auth: tokenAuth,
+	},
+	"generic": {
+		newNamed: newGeneric,
+		auth:     anyAuth(tokenAuth, signatureAuth(SignatureHeader)),
+	},
+	"ghcr": {
+		new:  func() DockerRegistryHandler { return new(GHCR) },
+		auth: signatureAuth("X-Hub-Signature-256"),
+	},
+	"gitlab": {
+		new:  func() DockerRegistryHandler { return new(Gitlab) },
+		auth: headerAuth("X-Gitlab-Token"),
+	},
+	"harbor": {
+		new: func() DockerRegistryHandler { return new(Harbor) },
+		// Harbor sends the "Auth Header" configured for the webhook in the Authorization header
+		auth: anyAuth(tokenAuth, headerAuth("Authorization")),
+	},
+	"quay": {
+		new:  func() DockerRegistryHandler { return new(Quay) },
+		auth: tokenAuth,
+	},
+	"registry": {
+		new:  func() DockerRegistryHandler { return new(DockerRegistry) },
+		auth: tokenAuth,
+	},
+}
+
+// DockerHandler returns a handler that receives docker registry webhooks
+// and enqueues the new tag to be applied to its manifests
+func DockerHandler(jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		registryType := vars["type"]
+
+		var dockerHandler DockerRegistryHandler
+		reg, ok := registries[registryType]
+		name, named := vars["name"]
+		switch {
+		case !ok:
+		case named && reg.newNamed != nil:
+			dockerHandler = reg.newNamed(name)
+			// Each named endpoint has its own secret and metrics
+			registryType += "_" + name
+		case !named && reg.new != nil:
+			dockerHandler = reg.new()
+		}
+		if dockerHandler == nil {
+			log.Printf("Unknown registry type: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		webhooksReceived.Add(registryType, 1)
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if secret := webhookSecret(registryType); secret != "" && !reg.auth(r, body, secret) {
+			log.Printf("Unauthorized %s webhook from %s", registryType, r.RemoteAddr)
+			webhooksUnauthorized.Add(registryType, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if d, ok := dockerHandler.(requestDecoder); ok {
+			err = d.Decode(r, body)
+		} else {
+			err = json.Unmarshal(body, dockerHandler)
+		}
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if rr, ok := dockerHandler.(responder); ok {
+			if resp := rr.Response(); resp != nil {
+				json.NewEncoder(w).Encode(resp)
+				return
+			}
+		}
+
+		var images []Image
+		if m, ok := dockerHandler.(multiImageHandler); ok {
+			images = m.Images()
+		} else {
+			name, tag := dockerHandler.NameAndTag()
+			images = []Image{{Name: name, Tag: tag}}
+		}
+
+		queued := make([]queue.Job, 0, len(images))
+		for _, image := range images {
+			name := image.resolveName()
+			job := queue.Job{Name: name, Tag: image.Tag, Aliases: image.otherNames(name)}
+			if c, ok := dockerHandler.(callbackHandler); ok {
+				job.CallbackURL = c.Callback()
+			}
+			queued = append(queued, job)
+		}
+		// Every image is queued or none are, so a registry that redelivers the webhook doesn't queue any of them twice
+		if err := jobs.EnqueueAll(queued); err != nil {
+			for _, image := range images {
+				log.Printf("%s:%s | %s", image.Name, image.Tag, err)
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		for i, image := range images {
+			if image.Digest != "" {
+				log.Printf("Queued %s:%s (%s) from %s webhook", queued[i].Name, queued[i].Tag, image.Digest, registryType)
+			} else {
+				log.Printf("Queued %s:%s from %s webhook", queued[i].Name, queued[i].Tag, registryType)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
 	}
-	name, tag := dockerHandler.NameAndTag()
+}
 
-	if match := config.GetManifest(name); match != nil {
-		match.GenerateGitUpdates(name, tag)
-	} else {
-		log.Printf("No matching manifest for %s:%s", name, tag)
+// QueueHandler returns a handler that reports the current state of the job queue
+func QueueHandler(jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jobs.Stats())
 	}
-	w.WriteHeader(http.StatusOK)
 }
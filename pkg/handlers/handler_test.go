@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"github.com/gorilla/mux"
+)
+
+func newRouter(jobs *queue.Queue) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/webhook/{type}", DockerHandler(jobs))
+	r.HandleFunc("/webhook/{type}/{name}", DockerHandler(jobs))
+	r.HandleFunc("/queue", QueueHandler(jobs))
+	return r
+}
+
+func TestDockerHandler(t *testing.T) {
+	tests := []struct {
+		path, body string
+		expected   int
+	}{
+		{"/webhook/dockerhub", `{"push_data":{"tag":"v1"},"repository":{"repo_name":"o/r"}}`, http.StatusOK},
+		{"/webhook/dockerhub", `not json`, http.StatusBadRequest},
+		{"/webhook/unknown", `{}`, http.StatusNotFound},
+		{"/webhook/generic", `{}`, http.StatusNotFound},
+		{"/webhook/dockerhub/named", `{}`, http.StatusNotFound},
+		// The queue only has room for one job
+		{"/webhook/dockerhub", `{"push_data":{"tag":"v2"},"repository":{"repo_name":"o/r"}}`, http.StatusServiceUnavailable},
+	}
+
+	jobs := queue.New(1, 1, nil, func(queue.Job) {})
+	r := newRouter(jobs)
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body)))
+		if w.Code != test.expected {
+			t.Errorf("%s %s | expected status: %d, got: %d", test.path, test.body, test.expected, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/queue", nil))
+	var stats queue.Stats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Error(err)
+	}
+	expected := queue.Stats{Depth: 1, Capacity: 1, Workers: 1}
+	if stats != expected {
+		t.Errorf("expected: %+v, got: %+v", expected, stats)
+	}
+}
+
+func TestDockerHandlerMultipleImages(t *testing.T) {
+	var got []queue.Job
+	jobs := queue.New(1, 10, nil, func(job queue.Job) { got = append(got, job) })
+	r := newRouter(jobs)
+
+	body := `{"repository":"o/r","updated_tags":["v1","v1.0"]}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/quay", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status: %d, got: %d", http.StatusOK, w.Code)
+	}
+
+	// Only one of the images fits, so neither is queued and the webhook can be redelivered
+	full := queue.New(1, 1, nil, func(queue.Job) {})
+	w = httptest.NewRecorder()
+	newRouter(full).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/quay", strings.NewReader(body)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status: %d, got: %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if stats := full.Stats(); stats.Depth != 0 {
+		t.Errorf("expected nothing to be queued, got: %+v", stats)
+	}
+
+	jobs.Start()
+	jobs.Stop()
+	expected := []queue.Job{{Name: "o/r", Tag: "v1"}, {Name: "o/r", Tag: "v1.0"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestDockerHandlerResponse(t *testing.T) {
+	jobs := queue.New(1, 10, nil, func(queue.Job) {})
+	r := newRouter(jobs)
+
+	body := `[{"eventType":"Microsoft.EventGrid.SubscriptionValidationEvent","data":{"validationCode":"abc"}}]`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/acr", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status: %d, got: %d", http.StatusOK, w.Code)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"validationResponse":"abc"}` {
+		t.Errorf("unexpected response: %s", got)
+	}
+	if stats := jobs.Stats(); stats.Depth != 0 {
+		t.Errorf("expected nothing to be queued, got: %+v", stats)
+	}
+}
+
+func TestDockerHandlerGeneric(t *testing.T) {
+	os.Setenv("GENERIC_WEBHOOKS_PATH", "../config/webhooks-test.yaml")
+	os.Setenv("WEBHOOK_SECRET_GENERIC_JENKINS", "s3cret")
+	defer os.Unsetenv("WEBHOOK_SECRET_GENERIC_JENKINS")
+
+	var got []queue.Job
+	jobs := queue.New(1, 10, nil, func(job queue.Job) { got = append(got, job) })
+	r := newRouter(jobs)
+
+	body := `{"image":{"name":"o/r","tag":"v1","digest":"sha256:abc"}}`
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/webhook/generic/jenkins?token=s3cret", http.StatusOK},
+		{"/webhook/generic/jenkins", http.StatusUnauthorized},
+		// buildkite doesn't have a secret
+		{"/webhook/generic/buildkite", http.StatusOK},
+		{"/webhook/generic/missing", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(body)))
+		if w.Code != test.expected {
+			t.Errorf("%s | expected status: %d, got: %d", test.path, test.expected, w.Code)
+		}
+	}
+
+	jobs.Start()
+	jobs.Stop()
+	// buildkite's paths aren't in the body, so there's nothing to queue
+	expected := []queue.Job{{Name: "o/r", Tag: "v1"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestImage_resolveName(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	tests := []struct {
+		image    Image
+		expected string
+	}{
+		{Image{Name: "docker-local/guestbook", Aliases: []string{"celfring/guestbook"}}, "celfring/guestbook"},
+		{Image{Name: "celfring/k8s-demo", Aliases: []string{"k8s-demo"}}, "celfring/k8s-demo"},
+		{Image{Name: "docker-local/no-entry", Aliases: []string{"no-entry"}}, "docker-local/no-entry"},
+		{Image{Name: "no-aliases"}, "no-aliases"},
+	}
+
+	for _, test := range tests {
+		if got := test.image.resolveName(); got != test.expected {
+			t.Errorf("%+v | expected: %s, got: %s", test.image, test.expected, got)
+		}
+	}
+}
+
+func TestDockerHandlerAliases(t *testing.T) {
+	f, err := ioutil.TempFile("", "manifest-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	// Both of the image's names have their own manifest
+	f.WriteString(`
+- docker_repo: docker-local/app
+  manifests:
+    - file: charts/app/values.yaml
+      config_repo: o/configs
+      base_branch: master
+- docker_repo: app
+  manifests:
+    - file: charts/app/values-staging.yaml
+      config_repo: o/configs
+      base_branch: master
+`)
+	f.Close()
+	os.Setenv("MANIFEST_PATH", f.Name())
+	if err := config.ReloadManifests(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+		config.ReloadManifests()
+	}()
+
+	var got []queue.Job
+	jobs := queue.New(1, 10, nil, func(job queue.Job) { got = append(got, job) })
+	r := newRouter(jobs)
+
+	body := `{"domain":"docker","event_type":"pushed","data":{"repo_key":"docker-local","image_name":"app","tag":"v1"}}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/artifactory", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status: %d, got: %d", http.StatusOK, w.Code)
+	}
+
+	jobs.Start()
+	jobs.Stop()
+	// The image is only queued once, with the manifests of both names
+	expected := []queue.Job{{Name: "docker-local/app", Tag: "v1", Aliases: []string{"app"}}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %+v, got: %+v", expected, got)
+	}
+	m := config.GetManifest(got[0].Name, got[0].Aliases...)
+	if m == nil {
+		t.Fatal("expected a manifest")
+	}
+	var files []string
+	for _, entry := range m.Manifests {
+		files = append(files, entry.File)
+	}
+	expectedFiles := []string{"charts/app/values.yaml", "charts/app/values-staging.yaml"}
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Errorf("expected: %v, got: %v", expectedFiles, files)
+	}
+}
+
+func TestDockerHandlerAuth(t *testing.T) {
+	os.Setenv("WEBHOOK_SECRET_DOCKERHUB", "s3cret")
+	defer os.Unsetenv("WEBHOOK_SECRET_DOCKERHUB")
+
+	body := `{"push_data":{"tag":"v1"},"repository":{"repo_name":"o/r"}}`
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/webhook/dockerhub", http.StatusUnauthorized},
+		{"/webhook/dockerhub?token=wrong", http.StatusUnauthorized},
+		{"/webhook/dockerhub?token=s3cret", http.StatusOK},
+	}
+
+	before := unauthorizedCount("dockerhub")
+	r := newRouter(queue.New(1, 10, nil, func(queue.Job) {}))
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(body)))
+		if w.Code != test.expected {
+			t.Errorf("%s | expected status: %d, got: %d", test.path, test.expected, w.Code)
+		}
+	}
+	if got := unauthorizedCount("dockerhub") - before; got != 2 {
+		t.Errorf("expected 2 unauthorized webhooks to be counted, got: %d", got)
+	}
+}
+
+func unauthorizedCount(registryType string) int64 {
+	if v, ok := webhooksUnauthorized.Get(registryType).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestDockerHandlerSignature(t *testing.T) {
+	os.Setenv("WEBHOOK_SECRET_GHCR", "s3cret")
+	defer os.Unsetenv("WEBHOOK_SECRET_GHCR")
+
+	body := `{"tag":"v1"}`
+	tests := []struct {
+		signature string
+		expected  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"sha256=00beae9ad4c228327e5c8946051a3eb649f74bcb0a9d55a5f4a151b15fd290f3", http.StatusUnauthorized},
+		{"sha256=4fbeae9ad4c228327e5c8946051a3eb649f74bcb0a9d55a5f4a151b15fd290f3", http.StatusOK},
+	}
+
+	r := newRouter(queue.New(1, 10, nil, func(queue.Job) {}))
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/ghcr", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", test.signature)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != test.expected {
+			t.Errorf("%s | expected status: %d, got: %d", test.signature, test.expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+// HarborPushArtifact is the type of Harbor event sent when an image is pushed
+const HarborPushArtifact = "PUSH_ARTIFACT"
+
+// Harbor is the body received from a Harbor webhook
+// https://goharbor.io/docs/main/working-with-projects/project-configuration/configure-webhooks/
+type Harbor struct {
+	Type      string          `json:"type"`
+	OccurAt   int             `json:"occur_at"`
+	Operator  string          `json:"operator"`
+	EventData HarborEventData `json:"event_data"`
+}
+
+type HarborEventData struct {
+	Resources  []HarborResource `json:"resources"`
+	Repository HarborRepository `json:"repository"`
+}
+
+type HarborResource struct {
+	Digest      string `json:"digest"`
+	Tag         string `json:"tag"`
+	ResourceURL string `json:"resource_url"`
+}
+
+type HarborRepository struct {
+	DateCreated  int    `json:"date_created"`
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	RepoFullName string `json:"repo_full_name"`
+	RepoType     string `json:"repo_type"`
+}
+
+// NameAndTag returns the first tag that was pushed. See Images for all of them
+func (h *Harbor) NameAndTag() (string, string) {
+	if images := h.Images(); len(images) > 0 {
+		return images[0].Name, images[0].Tag
+	}
+	return h.repoName(), ""
+}
+
+// Images returns an Image for every tagged resource that was pushed.
+// Events other than pushes are skipped.
+func (h *Harbor) Images() []Image {
+	images := []Image{}
+	if h.Type != HarborPushArtifact {
+		return images
+	}
+	for _, r := range h.EventData.Resources {
+		if r.Tag == "" {
+			continue
+		}
+		images = append(images, Image{Name: h.repoName(), Tag: r.Tag})
+	}
+	return images
+}
+
+// repoName includes the Harbor project, so it can match `docker_repo` like `project/repo`
+func (h *Harbor) repoName() string {
+	repo := h.EventData.Repository
+	if repo.Namespace == "" {
+		return repo.Name
+	}
+	return repo.Namespace + "/" + repo.Name
+}
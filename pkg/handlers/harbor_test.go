@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHarbor_Images(t *testing.T) {
+	body := `{
+	  "type": "PUSH_ARTIFACT",
+	  "occur_at": 1588711699,
+	  "operator": "robot$ci",
+	  "event_data": {
+	    "resources": [
+	      {
+	        "digest": "sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+	        "tag": "v1.2.0",
+	        "resource_url": "harbor.example.com/myProject/myRepo:v1.2.0"
+	      }
+	    ],
+	    "repository": {
+	      "date_created": 1588711000,
+	      "name": "myRepo",
+	      "namespace": "myProject",
+	      "repo_full_name": "myProject/myRepo",
+	      "repo_type": "private"
+	    }
+	  }
+	}`
+	var h Harbor
+	if err := json.Unmarshal([]byte(body), &h); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{{Name: "myProject/myRepo", Tag: "v1.2.0"}}
+	if got := h.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestHarbor_NameAndTag(t *testing.T) {
+	repo := HarborRepository{Name: "myRepo", Namespace: "myProject"}
+	tests := []struct {
+		name, tag string
+		harbor    Harbor
+	}{
+		{"myProject/myRepo", "v1", Harbor{
+			Type:      HarborPushArtifact,
+			EventData: HarborEventData{Repository: repo, Resources: []HarborResource{{Tag: "v1"}, {Tag: "v2"}}},
+		}},
+		// Only pushes have tags to update
+		{"myProject/myRepo", "", Harbor{
+			Type:      "DELETE_ARTIFACT",
+			EventData: HarborEventData{Repository: repo, Resources: []HarborResource{{Tag: "v1"}}},
+		}},
+		{"myRepo", "v1", Harbor{
+			Type:      HarborPushArtifact,
+			EventData: HarborEventData{Repository: HarborRepository{Name: "myRepo"}, Resources: []HarborResource{{Tag: "v1"}}},
+		}},
+	}
+
+	for _, test := range tests {
+		name, tag := test.harbor.NameAndTag()
+		if name != test.name {
+			t.Errorf("expected repo name: %s, got: %s", test.name, name)
+		}
+		if tag != test.tag {
+			t.Errorf("expected tag: %s, got: %s", test.tag, tag)
+		}
+	}
+}
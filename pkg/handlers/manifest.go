@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+)
+
+// GithubPush is the part of a GitHub push event used to refresh the manifest
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads#push
+type GithubPush struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// ManifestRefreshHandler returns a handler that reloads the manifest when it receives
+// a GitHub push event for MANIFEST_REPO's branch. It's authenticated with WEBHOOK_SECRET_MANIFEST.
+func ManifestRefreshHandler() http.HandlerFunc {
+	return manifestRefreshHandler(func() { config.ReloadManifests() })
+}
+
+// manifestRefreshHandler takes the reload func so it can be swapped out in tests
+func manifestRefreshHandler(reload func()) http.HandlerFunc {
+	auth := signatureAuth("X-Hub-Signature-256")
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if secret := webhookSecret("manifest"); secret != "" && !auth(r, body, secret) {
+			log.Printf("Unauthorized manifest refresh from %s", r.RemoteAddr)
+			webhooksUnauthorized.Add("manifest", 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-GitHub-Event") == "ping" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var push GithubPush
+		if err := json.Unmarshal(body, &push); err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		repo, branch := config.ManifestRepo()
+		if repo == "" || !strings.EqualFold(push.Repository.FullName, repo) || push.Ref != "refs/heads/"+branch {
+			// Pushes to other repos and branches don't change the manifest
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		log.Printf("received a push to %s@%s, reloading the manifest", repo, branch)
+		// GitHub doesn't wait long for a response, and a failed reload keeps the previous manifest
+		go reload()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
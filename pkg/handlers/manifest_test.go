@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestManifestRefreshHandler(t *testing.T) {
+	os.Setenv("MANIFEST_REPO", "o/configs")
+	defer os.Unsetenv("MANIFEST_REPO")
+	os.Setenv("WEBHOOK_SECRET_MANIFEST", "s3cret")
+	defer os.Unsetenv("WEBHOOK_SECRET_MANIFEST")
+
+	reloads := make(chan bool, 10)
+	handler := manifestRefreshHandler(func() { reloads <- true })
+
+	push := func(repo, ref string) string {
+		return `{"ref":"` + ref + `","repository":{"full_name":"` + repo + `"}}`
+	}
+	tests := []struct {
+		event, body string
+		signed      bool
+		expected    int
+		reload      bool
+	}{
+		{"push", push("o/configs", "refs/heads/master"), true, http.StatusAccepted, true},
+		{"push", push("O/Configs", "refs/heads/master"), true, http.StatusAccepted, true},
+		{"push", push("o/configs", "refs/heads/feature"), true, http.StatusOK, false},
+		{"push", push("o/other", "refs/heads/master"), true, http.StatusOK, false},
+		{"push", push("o/configs", "refs/heads/master"), false, http.StatusUnauthorized, false},
+		{"ping", `{"zen":"Keep it logically awesome."}`, true, http.StatusOK, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/manifest/refresh", strings.NewReader(test.body))
+		r.Header.Set("X-GitHub-Event", test.event)
+		if test.signed {
+			r.Header.Set("X-Hub-Signature-256", "sha256="+sign("s3cret", test.body))
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%s | expected status: %d, got: %d", test.body, test.expected, w.Code)
+		}
+		if test.reload {
+			<-reloads
+		}
+	}
+	if len(reloads) != 0 {
+		t.Errorf("expected 2 reloads, got %d more", len(reloads))
+	}
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package handlers
+
+// Quay is the body received from a Quay.io repository push notification
+// https://docs.quay.io/guides/notifications.html#repository-push
+type Quay struct {
+	Name        string   `json:"name"`
+	Repository  string   `json:"repository"`
+	Namespace   string   `json:"namespace"`
+	DockerURL   string   `json:"docker_url"`
+	Homepage    string   `json:"homepage"`
+	UpdatedTags []string `json:"updated_tags"`
+}
+
+// NameAndTag returns the first of the updated tags. See Images for all of them
+func (q *Quay) NameAndTag() (string, string) {
+	var tag string
+	if len(q.UpdatedTags) > 0 {
+		tag = q.UpdatedTags[0]
+	}
+	return q.Repository, tag
+}
+
+// Images returns an Image for every tag that was pushed, which can be matched in the
+// manifest by either the repository, or its docker URL like `quay.io/namespace/repo`
+func (q *Quay) Images() []Image {
+	images := make([]Image, 0, len(q.UpdatedTags))
+	for _, tag := range q.UpdatedTags {
+		image := Image{Name: q.Repository, Tag: tag}
+		if q.DockerURL != "" && q.DockerURL != q.Repository {
+			image.Aliases = []string{q.DockerURL}
+		}
+		images = append(images, image)
+	}
+	return images
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestQuay_NameAndTag(t *testing.T) {
+	tests := []struct {
+		name, tag string
+		quay      Quay
+	}{
+		{"myNamespace/myRepo", "v1", Quay{Repository: "myNamespace/myRepo", UpdatedTags: []string{"v1", "latest"}}},
+		{"myNamespace/myRepo", "", Quay{Repository: "myNamespace/myRepo"}},
+	}
+
+	for _, test := range tests {
+		name, tag := test.quay.NameAndTag()
+		if name != test.name {
+			t.Errorf("expected repo name: %s, got: %s", test.name, name)
+		}
+		if tag != test.tag {
+			t.Errorf("expected tag: %s, got: %s", test.tag, tag)
+		}
+	}
+}
+
+func TestQuay_Images(t *testing.T) {
+	body := `{
+	  "name": "myRepo",
+	  "repository": "myNamespace/myRepo",
+	  "namespace": "myNamespace",
+	  "docker_url": "quay.io/myNamespace/myRepo",
+	  "homepage": "https://quay.io/repository/myNamespace/myRepo",
+	  "updated_tags": ["v1.2.0", "latest"]
+	}`
+	var q Quay
+	if err := json.Unmarshal([]byte(body), &q); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{
+		{Name: "myNamespace/myRepo", Tag: "v1.2.0", Aliases: []string{"quay.io/myNamespace/myRepo"}},
+		{Name: "myNamespace/myRepo", Tag: "latest", Aliases: []string{"quay.io/myNamespace/myRepo"}},
+	}
+	if got := q.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+
+	// Without a docker URL, there's no other name to match
+	q = Quay{Repository: "myNamespace/myRepo", UpdatedTags: []string{"v1"}}
+	expected = []Image{{Name: "myNamespace/myRepo", Tag: "v1"}}
+	if got := q.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
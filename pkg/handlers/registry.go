@@ -0,0 +1,66 @@
+package handlers
+
+import "time"
+
+// DockerRegistry is the notification envelope sent by a Docker Registry v2 (distribution)
+// https://docs.docker.com/registry/notifications/
+type DockerRegistry struct {
+	Events []DockerRegistryEvent `json:"events"`
+}
+
+type DockerRegistryEvent struct {
+	ID        string                `json:"id"`
+	Timestamp time.Time             `json:"timestamp"`
+	Action    string                `json:"action"`
+	Target    DockerRegistryTarget  `json:"target"`
+	Request   DockerRegistryRequest `json:"request"`
+}
+
+type DockerRegistryTarget struct {
+	MediaType  string `json:"mediaType"`
+	Size       int    `json:"size"`
+	Digest     string `json:"digest"`
+	Length     int    `json:"length"`
+	Repository string `json:"repository"`
+	URL        string `json:"url"`
+	Tag        string `json:"tag"`
+}
+
+type DockerRegistryRequest struct {
+	ID        string `json:"id"`
+	Addr      string `json:"addr"`
+	Host      string `json:"host"`
+	Method    string `json:"method"`
+	UserAgent string `json:"useragent"`
+}
+
+// NameAndTag returns the first tagged push. See Images for all of them
+func (dr *DockerRegistry) NameAndTag() (string, string) {
+	if images := dr.Images(); len(images) > 0 {
+		return images[0].Name, images[0].Tag
+	}
+	return "", ""
+}
+
+// Images returns an Image for every tag that was pushed, which can be matched in the
+// manifest by either the repository, or the repository including the registry's host
+// like `registry.example.com:5000/repo`. Pulls, blob pushes and the untagged manifests
+// pushed as part of a manifest list are skipped, since they don't have a tag to update.
+func (dr *DockerRegistry) Images() []Image {
+	images := []Image{}
+	seen := map[string]bool{}
+	for _, e := range dr.Events {
+		if e.Action != "push" || e.Target.Tag == "" {
+			continue
+		}
+		if key := e.Target.Repository + ":" + e.Target.Tag; !seen[key] {
+			seen[key] = true
+			image := Image{Name: e.Target.Repository, Tag: e.Target.Tag}
+			if host := e.Request.Host; host != "" {
+				image.Aliases = []string{host + "/" + e.Target.Repository}
+			}
+			images = append(images, image)
+		}
+	}
+	return images
+}
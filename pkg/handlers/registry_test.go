@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const dockerRegistryBody = `{
+  "events": [
+    {
+      "id": "320678d8-ca14-430f-8bb6-4ca139cd83f7",
+      "timestamp": "2020-05-05T20:48:19.123456789Z",
+      "action": "push",
+      "target": {
+        "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+        "size": 708,
+        "digest": "sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+        "length": 708,
+        "repository": "team/app"
+      },
+      "request": {"host": "registry.example.com:5000", "method": "PUT"}
+    },
+    {
+      "id": "6b0a4a79-1c32-4d74-b1d1-5d8e0d5b6e47",
+      "timestamp": "2020-05-05T20:48:19.223456789Z",
+      "action": "push",
+      "target": {
+        "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+        "digest": "sha256:6d5e9e4d4c2a1b2f3e4d5c6b7a8f9e0d1c2b3a4f5e6d7c8b9a0f1e2d3c4b5a69",
+        "repository": "team/app",
+        "tag": "v1.2.0"
+      },
+      "request": {"host": "registry.example.com:5000", "method": "PUT"}
+    },
+    {
+      "id": "9c4e5cc7-9e0c-4d84-a4d5-0bbfc3f55d3d",
+      "timestamp": "2020-05-05T20:48:20Z",
+      "action": "pull",
+      "target": {
+        "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+        "repository": "team/other",
+        "tag": "v3"
+      },
+      "request": {"host": "registry.example.com:5000", "method": "GET"}
+    },
+    {
+      "id": "2d8b1e0e-3c5a-4b8e-9a8a-77a3fbd2b1c4",
+      "timestamp": "2020-05-05T20:48:21Z",
+      "action": "push",
+      "target": {
+        "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+        "repository": "team/worker",
+        "tag": "v2.0.1"
+      },
+      "request": {"host": "registry.example.com:5000", "method": "PUT"}
+    }
+  ]
+}`
+
+func TestDockerRegistry_Images(t *testing.T) {
+	var dr DockerRegistry
+	if err := json.Unmarshal([]byte(dockerRegistryBody), &dr); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{
+		{Name: "team/app", Tag: "v1.2.0", Aliases: []string{"registry.example.com:5000/team/app"}},
+		{Name: "team/worker", Tag: "v2.0.1", Aliases: []string{"registry.example.com:5000/team/worker"}},
+	}
+	if got := dr.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+
+	// Without the request's host, there's no other name to match
+	dr = DockerRegistry{Events: []DockerRegistryEvent{{Action: "push", Target: DockerRegistryTarget{Repository: "team/app", Tag: "v1"}}}}
+	expected = []Image{{Name: "team/app", Tag: "v1"}}
+	if got := dr.Images(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestDockerRegistry_NameAndTag(t *testing.T) {
+	tests := []struct {
+		name, tag string
+		registry  DockerRegistry
+	}{
+		{"team/app", "v1", DockerRegistry{Events: []DockerRegistryEvent{
+			{Action: "pull", Target: DockerRegistryTarget{Repository: "team/other", Tag: "v2"}},
+			{Action: "push", Target: DockerRegistryTarget{Repository: "team/app", Tag: "v1"}},
+		}}},
+		{"", "", DockerRegistry{}},
+	}
+
+	for _, test := range tests {
+		name, tag := test.registry.NameAndTag()
+		if name != test.name {
+			t.Errorf("expected repo name: %s, got: %s", test.name, name)
+		}
+		if tag != test.tag {
+			t.Errorf("expected tag: %s, got: %s", test.tag, tag)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package poller
+
+import (
+	"log"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"golang.org/x/mod/semver"
+)
+
+// Poller finds new tags by listing the tags of every docker repo in the manifest,
+// for registries that can't send webhooks. New tags are enqueued the same way webhooks are.
+type Poller struct {
+	enqueue func(queue.Job) error
+	// these are swapped out in tests
+	tags       func(dockerRepo string) ([]string, error)
+	manifests  func() config.ManifestConfigs
+	currentTag func(entry config.ManifestEntry) (string, error)
+
+	// latest is the newest tag seen for each docker repo, so a tag is only enqueued once
+	latest map[string]string
+	stop   chan struct{}
+}
+
+func New(jobs *queue.Queue) *Poller {
+	return &Poller{
+		enqueue:    jobs.Enqueue,
+		tags:       newRegistryClient().Tags,
+		manifests:  config.GetManifests,
+		currentTag: config.ManifestEntry.CurrentTag,
+		latest:     map[string]string{},
+		stop:       make(chan struct{}),
+	}
+}
+
+// Poll enqueues the newest tag of every docker repo that is newer than the tag in
+// any of its manifest files. It returns the number of jobs that were enqueued.
+func (p *Poller) Poll() int {
+	n := 0
+	// The docker repos that match a pattern can't be listed, so only the others are checked
+	for _, mc := range p.manifests().Resolved() {
+		tags, err := p.tags(mc.DockerRepo)
+		if err != nil {
+			log.Printf("%s | failed to poll tags: %s", mc.DockerRepo, err)
+			continue
+		}
+		newest := newestTag(tags)
+		if newest == "" || p.latest[mc.DockerRepo] == newest {
+			continue
+		}
+		if !p.outdated(mc, newest) {
+			p.latest[mc.DockerRepo] = newest
+			continue
+		}
+
+		if err := p.enqueue(queue.Job{Name: mc.DockerRepo, Tag: newest}); err != nil {
+			// It's enqueued on the next poll instead
+			log.Printf("%s:%s | %s", mc.DockerRepo, newest, err)
+			continue
+		}
+		p.latest[mc.DockerRepo] = newest
+		log.Printf("Queued %s:%s from registry poll", mc.DockerRepo, newest)
+		n++
+	}
+	return n
+}
+
+// outdated returns true if any of the manifest files has a tag older than tag
+func (p *Poller) outdated(mc config.ManifestConfig, tag string) bool {
+	for _, entry := range mc.Manifests {
+		current, err := p.currentTag(entry)
+		if err != nil {
+			log.Printf("%s | failed to read the current tag of %s/%s (%s): %s", mc.DockerRepo, entry.ConfigRepo, entry.File, entry.Source, err)
+			continue
+		}
+		// The result will be 0 if a == b, -1 if a < b, or +1 if a > b
+		if semver.Compare(tag, current) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Every polls on the given interval, until Stop is called
+func (p *Poller) Every(interval time.Duration) {
+	every(interval, p.stop, func() { p.Poll() })
+}
+
+func (p *Poller) Stop() {
+	close(p.stop)
+}
+
+// every calls f right away, and then on the given interval until stop is closed
+func every(interval time.Duration, stop <-chan struct{}, f func()) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		f()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				f()
+			}
+		}
+	}()
+}
+
+// newestTag returns the highest semver tag, or "" if none of the tags are semver
+func newestTag(tags []string) string {
+	newest := ""
+	for _, tag := range tags {
+		if !semver.IsValid(tag) {
+			continue
+		}
+		if newest == "" || semver.Compare(tag, newest) > 0 {
+			newest = tag
+		}
+	}
+	return newest
+}
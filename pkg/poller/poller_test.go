@@ -0,0 +1,91 @@
+package poller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/queue"
+)
+
+func TestPoller_Poll(t *testing.T) {
+	tags := map[string][]string{
+		"celfring/guestbook": {"v1.0.0", "v1.2.0", "latest", "v1.1.0"},
+		"celfring/k8s-demo":  {"v2.0.0"},
+		"celfring/broken":    nil,
+	}
+	current := map[string]string{
+		"charts/guestbook/values.yaml": "v1.0.0",
+		"charts/k8s-demo/values.yaml":  "v2.0.0",
+	}
+	entry := func(file string) []config.ManifestEntry {
+		return []config.ManifestEntry{{ConfigRepo: "o/configs", File: file, BaseBranch: "master"}}
+	}
+
+	var got []queue.Job
+	p := New(queue.New(1, 1, nil, func(queue.Job) {}))
+	p.enqueue = func(job queue.Job) error {
+		got = append(got, job)
+		return nil
+	}
+	p.tags = func(dockerRepo string) ([]string, error) {
+		if dockerRepo == "celfring/*" {
+			t.Error("patterns shouldn't be polled")
+		}
+		if dockerRepo == "celfring/broken" {
+			return nil, errors.New("500 Internal Server Error")
+		}
+		return tags[dockerRepo], nil
+	}
+	p.manifests = func() config.ManifestConfigs {
+		return config.ManifestConfigs{
+			{DockerRepo: "celfring/guestbook", Manifests: entry("charts/guestbook/values.yaml")},
+			{DockerRepo: "celfring/k8s-demo", Manifests: entry("charts/k8s-demo/values.yaml")},
+			{DockerRepo: "celfring/broken", Manifests: entry("charts/broken/values.yaml")},
+			{DockerRepo: "celfring/*", Manifests: entry("charts/{{.name}}/values.yaml")},
+			// Another team's docker_repo for the same image is merged, and it's only polled once
+			{DockerRepo: "celfring/guestbook", Manifests: entry("charts/guestbook/values-staging.yaml")},
+		}
+	}
+	p.currentTag = func(entry config.ManifestEntry) (string, error) {
+		return current[entry.File], nil
+	}
+
+	if n := p.Poll(); n != 1 {
+		t.Errorf("expected 1 job to be enqueued, got: %d", n)
+	}
+	expected := []queue.Job{{Name: "celfring/guestbook", Tag: "v1.2.0"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+
+	// Tags are only enqueued once, even if the manifest isn't updated yet
+	if n := p.Poll(); n != 0 {
+		t.Errorf("expected nothing to be enqueued, got: %d", n)
+	}
+
+	tags["celfring/k8s-demo"] = append(tags["celfring/k8s-demo"], "v2.1.0-rc.1")
+	p.Poll()
+	expected = append(expected, queue.Job{Name: "celfring/k8s-demo", Tag: "v2.1.0-rc.1"})
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestNewestTag(t *testing.T) {
+	tests := []struct {
+		tags     []string
+		expected string
+	}{
+		{[]string{"v1.0.0", "v1.10.0", "v1.9.0"}, "v1.10.0"},
+		{[]string{"v1.0.0", "v1.1.0-rc.1", "v1.0.1"}, "v1.1.0-rc.1"},
+		{[]string{"latest", "main"}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := newestTag(test.tags); got != test.expected {
+			t.Errorf("%v | expected: %s, got: %s", test.tags, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package poller
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"github.com/RentTheRunway/blanche/pkg/store"
+	"golang.org/x/mod/semver"
+)
+
+// Drift is a manifest file with an older tag than the newest one known for its docker repo
+type Drift struct {
+	DockerRepo string `json:"docker_repo"`
+	ConfigRepo string `json:"config_repo"`
+	File       string `json:"file"`
+	BaseBranch string `json:"base_branch"`
+	CurrentTag string `json:"current_tag"`
+	NewestTag  string `json:"newest_tag"`
+	// Queued is true if an update was enqueued to heal the drift
+	Queued bool `json:"queued"`
+	// Pending is true if a pull request to the newest tag was already opened, and is waiting to be merged
+	Pending bool `json:"pending"`
+}
+
+// Reconciler finds manifest files that have drifted from the newest tag of their docker repo,
+// like when a webhook was missed or a file was edited by hand, and enqueues updates to heal them.
+// The newest tag is taken from the tags blanche has received, and from the registry when Registry is true.
+type Reconciler struct {
+	// DryRun only reports drift, without enqueuing any updates
+	DryRun   bool
+	Registry bool
+
+	enqueue func(queue.Job) error
+	// these are swapped out in tests
+	history    func(dockerRepo string) []string
+	tags       func(dockerRepo string) ([]string, error)
+	manifests  func() config.ManifestConfigs
+	currentTag func(entry config.ManifestEntry) (string, error)
+	pending    func(entry config.ManifestEntry, dockerRepo, tag string) (bool, error)
+
+	mu    sync.Mutex
+	drift []Drift
+	// queued is the tag enqueued for each drifted docker repo, so it's only enqueued once
+	queued map[string]string
+	stop   chan struct{}
+}
+
+func NewReconciler(jobs *queue.Queue, s *store.Store) *Reconciler {
+	return &Reconciler{
+		enqueue:    jobs.Enqueue,
+		history:    s.Tags,
+		tags:       newRegistryClient().Tags,
+		manifests:  config.GetManifests,
+		currentTag: config.ManifestEntry.CurrentTag,
+		pending:    config.ManifestEntry.ReleasePending,
+		queued:     map[string]string{},
+		stop:       make(chan struct{}),
+	}
+}
+
+// Reconcile compares every manifest file against the newest tag of its docker repo,
+// and enqueues an update for each docker repo that has drifted. It returns the drift it found.
+func (r *Reconciler) Reconcile() []Drift {
+	var found []Drift
+	// The docker repos that match a pattern can't be known, so only the others are checked
+	for _, mc := range r.manifests().Resolved() {
+		newest := r.newest(mc.DockerRepo)
+		if newest == "" {
+			continue
+		}
+
+		var drift []Drift
+		for _, entry := range mc.Manifests {
+			current, err := r.currentTag(entry)
+			if err != nil {
+				log.Printf("%s | failed to read the current tag of %s/%s (%s): %s", mc.DockerRepo, entry.ConfigRepo, entry.File, entry.Source, err)
+				continue
+			}
+			// The result will be 0 if a == b, -1 if a < b, or +1 if a > b
+			if semver.Compare(newest, current) <= 0 {
+				continue
+			}
+			d := Drift{
+				DockerRepo: mc.DockerRepo,
+				ConfigRepo: entry.ConfigRepo,
+				File:       entry.File,
+				BaseBranch: entry.BaseBranch,
+				CurrentTag: current,
+				NewestTag:  newest,
+			}
+			if entry.PullRequest {
+				// The file is behind until its pull request is merged, which doesn't need to be healed
+				if d.Pending, err = r.pending(entry, mc.DockerRepo, newest); err != nil {
+					log.Printf("%s | failed to check for a pull request to %s on %s/%s: %s", mc.DockerRepo, newest, entry.ConfigRepo, entry.BaseBranch, err)
+				}
+			}
+			drift = append(drift, d)
+		}
+		if len(drift) == 0 {
+			// Once healed, the docker repo can be enqueued again if it drifts again
+			r.mu.Lock()
+			delete(r.queued, mc.DockerRepo)
+			r.mu.Unlock()
+			continue
+		}
+
+		queued := false
+		for _, d := range drift {
+			if !d.Pending {
+				queued = r.heal(mc.DockerRepo, newest)
+				break
+			}
+		}
+		for _, d := range drift {
+			if d.Pending {
+				log.Printf("%s | %s/%s@%s is at %s, and a pull request to %s is pending", d.DockerRepo, d.ConfigRepo, d.File, d.BaseBranch, d.CurrentTag, d.NewestTag)
+			} else {
+				d.Queued = queued
+				log.Printf("%s | %s/%s@%s is at %s, but the newest tag is %s", d.DockerRepo, d.ConfigRepo, d.File, d.BaseBranch, d.CurrentTag, d.NewestTag)
+			}
+			found = append(found, d)
+		}
+	}
+
+	r.mu.Lock()
+	r.drift = found
+	r.mu.Unlock()
+	return found
+}
+
+// heal enqueues an update to the newest tag, unless it's a dry run.
+// It returns true if the update is queued.
+func (r *Reconciler) heal(dockerRepo, tag string) bool {
+	if r.DryRun {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.queued[dockerRepo] == tag {
+		return true
+	}
+	if err := r.enqueue(queue.Job{Name: dockerRepo, Tag: tag}); err != nil {
+		log.Printf("%s:%s | %s", dockerRepo, tag, err)
+		return false
+	}
+	r.queued[dockerRepo] = tag
+	log.Printf("Queued %s:%s from reconciliation", dockerRepo, tag)
+	return true
+}
+
+// newest returns the newest semver tag known for the docker repo
+func (r *Reconciler) newest(dockerRepo string) string {
+	tags := r.history(dockerRepo)
+	if r.Registry {
+		registryTags, err := r.tags(dockerRepo)
+		if err != nil {
+			log.Printf("%s | failed to list tags, using received tags only: %s", dockerRepo, err)
+		}
+		tags = append(tags, registryTags...)
+	}
+	return newestTag(tags)
+}
+
+// Drift returns the drift found by the last reconciliation
+func (r *Reconciler) Drift() []Drift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.drift
+}
+
+// Every reconciles on the given interval, until Stop is called
+func (r *Reconciler) Every(interval time.Duration) {
+	every(interval, r.stop, func() { r.Reconcile() })
+}
+
+func (r *Reconciler) Stop() {
+	close(r.stop)
+}
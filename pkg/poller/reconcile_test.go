@@ -0,0 +1,162 @@
+package poller
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/queue"
+	"github.com/RentTheRunway/blanche/pkg/store"
+)
+
+func TestReconciler_Reconcile(t *testing.T) {
+	history := map[string][]string{
+		"celfring/guestbook": {"v1.0.0", "v1.1.0"},
+		"celfring/k8s-demo":  {"v2.0.0"},
+	}
+	current := map[string]string{
+		"charts/guestbook/values.yaml": "v1.1.0",
+		"charts/guestbook/prod.yaml":   "v1.0.0",
+		"charts/k8s-demo/values.yaml":  "v2.0.0",
+	}
+
+	var got []queue.Job
+	r := NewReconciler(queue.New(1, 1, nil, func(queue.Job) {}), newStore(t))
+	r.enqueue = func(job queue.Job) error {
+		got = append(got, job)
+		return nil
+	}
+	r.history = func(dockerRepo string) []string { return history[dockerRepo] }
+	r.tags = func(dockerRepo string) ([]string, error) {
+		if dockerRepo == "celfring/k8s-demo" {
+			return []string{"v2.1.0"}, nil
+		}
+		return nil, errors.New("500 Internal Server Error")
+	}
+	r.manifests = func() config.ManifestConfigs {
+		return config.ManifestConfigs{
+			{DockerRepo: "celfring/guestbook", Manifests: []config.ManifestEntry{
+				{ConfigRepo: "o/configs", File: "charts/guestbook/values.yaml", BaseBranch: "master"},
+				{ConfigRepo: "o/configs", File: "charts/guestbook/prod.yaml", BaseBranch: "master"},
+			}},
+			{DockerRepo: "celfring/k8s-demo", Manifests: []config.ManifestEntry{
+				{ConfigRepo: "o/configs", File: "charts/k8s-demo/values.yaml", BaseBranch: "master"},
+			}},
+		}
+	}
+	r.currentTag = func(entry config.ManifestEntry) (string, error) {
+		return current[entry.File], nil
+	}
+
+	// A dry run only reports drift
+	r.DryRun = true
+	expected := []Drift{{
+		DockerRepo: "celfring/guestbook",
+		ConfigRepo: "o/configs",
+		File:       "charts/guestbook/prod.yaml",
+		BaseBranch: "master",
+		CurrentTag: "v1.0.0",
+		NewestTag:  "v1.1.0",
+	}}
+	if drift := r.Reconcile(); !reflect.DeepEqual(drift, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, drift)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected nothing to be enqueued in a dry run, got: %+v", got)
+	}
+
+	// Tags in the registry are only used when Registry is true
+	r.DryRun = false
+	r.Registry = true
+	drift := r.Reconcile()
+	if len(drift) != 2 || !drift[0].Queued || !drift[1].Queued || drift[1].NewestTag != "v2.1.0" {
+		t.Errorf("expected 2 queued drifts, got: %+v", drift)
+	}
+	expectedJobs := []queue.Job{{Name: "celfring/guestbook", Tag: "v1.1.0"}, {Name: "celfring/k8s-demo", Tag: "v2.1.0"}}
+	if !reflect.DeepEqual(got, expectedJobs) {
+		t.Errorf("expected: %+v, got: %+v", expectedJobs, got)
+	}
+
+	// Drift that's already been queued isn't enqueued again
+	r.Reconcile()
+	if len(got) != 2 {
+		t.Errorf("expected no more jobs, got: %+v", got)
+	}
+
+	// Once healed, drifting again is enqueued again
+	current["charts/guestbook/prod.yaml"] = "v1.1.0"
+	r.Reconcile()
+	current["charts/guestbook/prod.yaml"] = "v1.0.0"
+	r.Reconcile()
+	if len(got) != 3 || !reflect.DeepEqual(got[2], expectedJobs[0]) {
+		t.Errorf("expected %+v to be enqueued again, got: %+v", expectedJobs[0], got)
+	}
+	if d := r.Drift(); len(d) != 2 {
+		t.Errorf("expected the last drift to be kept, got: %+v", d)
+	}
+}
+
+func TestReconciler_ReconcilePending(t *testing.T) {
+	var got []queue.Job
+	r := NewReconciler(queue.New(1, 1, nil, func(queue.Job) {}), newStore(t))
+	r.enqueue = func(job queue.Job) error {
+		got = append(got, job)
+		return nil
+	}
+	r.history = func(dockerRepo string) []string { return []string{"v1.1.0"} }
+	r.manifests = func() config.ManifestConfigs {
+		return config.ManifestConfigs{
+			{DockerRepo: "celfring/k8s-demo", Manifests: []config.ManifestEntry{
+				{ConfigRepo: "o/configs", File: "charts/k8s-demo/values.yaml", BaseBranch: "master", PullRequest: true},
+				{ConfigRepo: "o/configs", File: "charts/k8s-demo/prod.yaml", BaseBranch: "master", PullRequest: true},
+			}},
+		}
+	}
+	r.currentTag = func(entry config.ManifestEntry) (string, error) { return "v1.0.0", nil }
+	// The pull request for values.yaml was opened, but hasn't been merged yet
+	opened := map[string]bool{"charts/k8s-demo/values.yaml": true}
+	r.pending = func(entry config.ManifestEntry, dockerRepo, tag string) (bool, error) {
+		if dockerRepo != "celfring/k8s-demo" || tag != "v1.1.0" {
+			t.Errorf("unexpected pull request check for %s:%s", dockerRepo, tag)
+		}
+		return opened[entry.File], nil
+	}
+
+	drift := r.Reconcile()
+	if len(drift) != 2 || !drift[0].Pending || drift[0].Queued || drift[1].Pending || !drift[1].Queued {
+		t.Errorf("expected a pending drift and a queued drift, got: %+v", drift)
+	}
+	if len(got) != 1 {
+		t.Errorf("expected 1 job to be enqueued, got: %+v", got)
+	}
+
+	// Once both pull requests are open, like after a restart, nothing is enqueued
+	opened["charts/k8s-demo/prod.yaml"] = true
+	r.queued = map[string]string{}
+	r.enqueue = func(job queue.Job) error {
+		t.Errorf("unexpected job: %+v", job)
+		return nil
+	}
+	for _, d := range r.Reconcile() {
+		if !d.Pending || d.Queued {
+			t.Errorf("expected the drift to be pending, got: %+v", d)
+		}
+	}
+}
+
+func newStore(t *testing.T) *store.Store {
+	dir, err := ioutil.TempDir("", "blanche-poller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	s, err := store.Open(filepath.Join(dir, "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+package poller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+)
+
+const (
+	// DockerHubRegistry is the registry of images without a host, like `celfring/guestbook`
+	DockerHubRegistry = "registry-1.docker.io"
+)
+
+var ErrUnauthorized = errors.New("Unauthorized to list tags")
+
+var (
+	challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	nextLink       = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+)
+
+// splitReference splits a docker repo, like `quay.io/org/app` or `celfring/guestbook`,
+// into its registry host and its repository in that registry
+func splitReference(dockerRepo string) (host, repository string) {
+	parts := strings.SplitN(dockerRepo, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	if len(parts) == 1 {
+		// Official images, like `nginx`
+		return DockerHubRegistry, "library/" + dockerRepo
+	}
+	return DockerHubRegistry, dockerRepo
+}
+
+// registryClient lists tags with the Docker Registry HTTP API v2
+// https://docs.docker.com/registry/spec/api/#listing-image-tags
+type registryClient struct {
+	client *http.Client
+	// registries returns the credentials for a registry host, and is swapped out in tests
+	registries func(host string) *config.RegistryConfig
+}
+
+func newRegistryClient() *registryClient {
+	return &registryClient{
+		client:     &http.Client{Timeout: 30 * time.Second},
+		registries: config.GetRegistry,
+	}
+}
+
+// Tags returns every tag of the docker repo, following pagination
+func (c *registryClient) Tags(dockerRepo string) ([]string, error) {
+	host, repository := splitReference(dockerRepo)
+	creds := c.registries(host)
+	scheme := "https"
+	if creds != nil && creds.Insecure {
+		scheme = "http"
+	}
+
+	var tags []string
+	token := ""
+	next := fmt.Sprintf("%s://%s/v2/%s/tags/list", scheme, host, repository)
+	for next != "" {
+		resp, err := c.get(next, creds, token)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			// Most registries require a bearer token, which is fetched with the credentials
+			token, err = c.token(resp.Header.Get("WWW-Authenticate"), creds)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := decode(resp, &page); err != nil {
+			return nil, fmt.Errorf("listing tags of %s: %s", dockerRepo, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next = ""
+		if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			link, err := resp.Request.URL.Parse(m[1])
+			if err != nil {
+				return nil, err
+			}
+			next = link.String()
+		}
+	}
+	return tags, nil
+}
+
+func (c *registryClient) get(u string, creds *config.RegistryConfig, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case creds != nil && creds.Username != "":
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	return c.client.Do(req)
+}
+
+// token fetches a bearer token for the challenge in a WWW-Authenticate header, like
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:o/r:pull"`
+// https://docs.docker.com/registry/spec/auth/token/
+func (c *registryClient) token(challenge string, creds *config.RegistryConfig) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", ErrUnauthorized
+	}
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", ErrUnauthorized
+	}
+	q := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if v := params[key]; v != "" {
+			q.Set(key, v)
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	resp, err := c.get(realm.String(), creds, "")
+	if err != nil {
+		return "", err
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decode(resp, &t); err != nil {
+		return "", fmt.Errorf("fetching token from %s: %s", realm.Host, err)
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	if t.Token == "" {
+		return "", ErrUnauthorized
+	}
+	return t.Token, nil
+}
+
+// decode reads a JSON response, and closes its body
+func decode(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,87 @@
+package poller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+)
+
+func TestSplitReference(t *testing.T) {
+	tests := []struct {
+		value, host, repository string
+	}{
+		{"celfring/guestbook", DockerHubRegistry, "celfring/guestbook"},
+		{"nginx", DockerHubRegistry, "library/nginx"},
+		{"quay.io/org/app", "quay.io", "org/app"},
+		{"localhost:5000/app", "localhost:5000", "app"},
+		{"localhost/team/app", "localhost", "team/app"},
+	}
+	for _, test := range tests {
+		host, repository := splitReference(test.value)
+		if host != test.host || repository != test.repository {
+			t.Errorf("%s | expected: %s %s, got: %s %s", test.value, test.host, test.repository, host, repository)
+		}
+	}
+}
+
+// newRegistry stands in for a registry:2 that uses token auth, and returns a page per tag
+func newRegistry(t *testing.T, tags []string) (host string) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	host = strings.TrimPrefix(server.URL, "http://")
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "blanche" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if scope := r.URL.Query().Get("scope"); scope != "repository:team/app:pull" {
+			t.Errorf("unexpected scope: %s", scope)
+		}
+		fmt.Fprint(w, `{"token":"t0ken"}`)
+	})
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:team/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		i := 0
+		fmt.Sscan(r.URL.Query().Get("last"), &i)
+		if i+1 < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/team/app/tags/list?n=1&last=%d>; rel="next"`, i+1))
+		}
+		fmt.Fprintf(w, `{"name":"team/app","tags":["%s"]}`, tags[i])
+	})
+	return host
+}
+
+func TestRegistryClient_Tags(t *testing.T) {
+	expected := []string{"v1.0.0", "latest", "v1.1.0"}
+	host := newRegistry(t, expected)
+
+	c := newRegistryClient()
+	c.registries = func(h string) *config.RegistryConfig {
+		return &config.RegistryConfig{Host: h, Username: "blanche", Password: "s3cret", Insecure: true}
+	}
+	got, err := c.Tags(host + "/team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+
+	c.registries = func(h string) *config.RegistryConfig {
+		return &config.RegistryConfig{Host: h, Username: "blanche", Password: "wrong", Insecure: true}
+	}
+	if _, err := c.Tags(host + "/team/app"); err == nil || !strings.Contains(err.Error(), ErrUnauthorized.Error()) {
+		t.Errorf("expected error: %s, got: %v", ErrUnauthorized, err)
+	}
+}
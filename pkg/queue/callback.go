@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/store"
+)
+
+const (
+	CallbackSuccess = "success"
+	CallbackFailure = "failure"
+	CallbackError   = "error"
+
+	callbackContext = "blanche"
+)
+
+var callbackClient = &http.Client{Timeout: 10 * time.Second}
+
+// Callback reports the outcome of an event back to the registry that sent it.
+// See https://docs.docker.com/docker-hub/webhooks/#validate-a-webhook-callback
+type Callback struct {
+	State       string `json:"state"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// newCallback summarizes a finished event. The state is a failure when an update
+// was rejected, and an error when an update ran out of retries.
+func newCallback(event store.Event) Callback {
+	c := Callback{State: CallbackSuccess, Context: callbackContext}
+	if len(event.Updates) == 0 {
+		c.Description = fmt.Sprintf("No manifests to update for %s:%s", event.Name, event.Tag)
+		return c
+	}
+
+	var updated, failed []string
+	for _, u := range event.Updates {
+		file := u.Entry.ConfigRepo + "/" + u.Entry.File
+		switch u.Status {
+		case store.StatusDone:
+			updated = append(updated, file)
+			if c.TargetURL == "" {
+				c.TargetURL = u.URL
+			}
+		case store.StatusDeadLetter:
+			c.State = CallbackError
+			failed = append(failed, file)
+		default:
+			if c.State == CallbackSuccess {
+				c.State = CallbackFailure
+			}
+			failed = append(failed, file)
+		}
+	}
+
+	c.Description = fmt.Sprintf("Updated %d of %d manifests", len(updated), len(event.Updates))
+	if len(updated) > 0 {
+		c.Description += ": " + strings.Join(updated, ", ")
+	}
+	if len(failed) > 0 {
+		c.Description += ". Failed: " + strings.Join(failed, ", ")
+	}
+	return c
+}
+
+func postCallback(callbackURL string, payload Callback) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := callbackClient.Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("callback returned %s", resp.Status)
+	}
+	return nil
+}
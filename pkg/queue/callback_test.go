@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/store"
+)
+
+func TestNewCallback(t *testing.T) {
+	values := config.ManifestEntry{ConfigRepo: "o/configs", File: "values.yaml"}
+	prod := config.ManifestEntry{ConfigRepo: "o/configs", File: "values-production.yaml"}
+
+	tests := []struct {
+		updates  []store.Update
+		expected Callback
+	}{
+		{nil, Callback{State: CallbackSuccess, Context: "blanche", Description: "No manifests to update for o/r:v1"}},
+		{
+			[]store.Update{
+				{Entry: values, Status: store.StatusDone, URL: "https://github.com/o/configs/commit/abc"},
+				{Entry: prod, Status: store.StatusDone, URL: "https://github.com/o/configs/pull/1"},
+			},
+			Callback{
+				State:       CallbackSuccess,
+				Context:     "blanche",
+				Description: "Updated 2 of 2 manifests: o/configs/values.yaml, o/configs/values-production.yaml",
+				TargetURL:   "https://github.com/o/configs/commit/abc",
+			},
+		},
+		{
+			[]store.Update{
+				{Entry: values, Status: store.StatusFailed},
+				{Entry: prod, Status: store.StatusDone, URL: "https://github.com/o/configs/pull/1"},
+			},
+			Callback{
+				State:       CallbackFailure,
+				Context:     "blanche",
+				Description: "Updated 1 of 2 manifests: o/configs/values-production.yaml. Failed: o/configs/values.yaml",
+				TargetURL:   "https://github.com/o/configs/pull/1",
+			},
+		},
+		{
+			[]store.Update{
+				{Entry: values, Status: store.StatusFailed},
+				{Entry: prod, Status: store.StatusDeadLetter},
+			},
+			Callback{
+				State:       CallbackError,
+				Context:     "blanche",
+				Description: "Updated 0 of 2 manifests. Failed: o/configs/values.yaml, o/configs/values-production.yaml",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := newCallback(store.Event{Name: "o/r", Tag: "v1", Done: true, Updates: test.updates})
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected: %+v, got: %+v", test.expected, got)
+		}
+	}
+}
+
+func TestPostCallback(t *testing.T) {
+	payload := Callback{State: CallbackSuccess, Context: "blanche", Description: "Updated 1 of 1 manifests"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got Callback
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if got != payload {
+			t.Errorf("expected: %+v, got: %+v", payload, got)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	if err := postCallback(server.URL+"/ok", payload); err != nil {
+		t.Error(err)
+	}
+	if err := postCallback(server.URL+"/fail", payload); err == nil {
+		t.Error("should have returned an error, but got nil")
+	}
+}
@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime/debug"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/cloudevents"
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/gh"
+	"github.com/RentTheRunway/blanche/pkg/store"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = 30 * time.Second
+	DefaultMaxDelay    = 10 * time.Minute
+	// DefaultEventSource is the source of the CloudEvents blanche sends
+	DefaultEventSource = "blanche"
+)
+
+// Processor applies jobs to their manifests and records the outcome of each
+// ManifestEntry in the store. Updates that fail with a retryable error are
+// retried with exponential backoff until MaxAttempts is reached, after which
+// they are moved to the dead letters.
+type Processor struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// EventSink is a URL that a CloudEvent is sent to for every successful update.
+	// Nothing is sent when it's empty.
+	EventSink   string
+	EventSource string
+
+	store *store.Store
+	// update and callback are swapped out in tests
+	update   func(entry config.ManifestEntry, name, tag string) (string, error)
+	callback func(callbackURL string, payload Callback) error
+	send     func(sink string, e cloudevents.Event) error
+}
+
+func NewProcessor(s *store.Store) *Processor {
+	return &Processor{
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		store:       s,
+		update: func(entry config.ManifestEntry, name, tag string) (string, error) {
+			return entry.CreateGitUpdate(name, tag)
+		},
+		callback:    postCallback,
+		send:        cloudevents.Send,
+		EventSource: DefaultEventSource,
+	}
+}
+
+func (p *Processor) Process(job Job) {
+	event, ok := p.store.Get(job.ID)
+	if !ok {
+		log.Printf("%s:%s | event %s not found in store", job.Name, job.Tag, job.ID)
+		return
+	}
+
+	if event.Updates == nil {
+		if !p.resolve(&event) {
+			event.Done = true
+			p.finish(event)
+			return
+		}
+		p.save(event)
+	}
+
+	event.Done = true
+	for i := range event.Updates {
+		update := &event.Updates[i]
+		now := time.Now().UTC()
+		if !update.Due(now) {
+			if update.Status == store.StatusRetrying {
+				event.Done = false
+			}
+			continue
+		}
+
+		if update.Attempts >= p.MaxAttempts {
+			// The last attempt never finished, like when blanche was restarted while making it
+			log.Printf("%s:%s | giving up after %d attempts, the last one was interrupted\n%+v", event.Name, event.Tag, update.Attempts, update.Entry)
+			update.Status = store.StatusDeadLetter
+			update.Error = "interrupted during the last attempt"
+			update.UpdatedAt = now
+			p.save(event)
+			continue
+		}
+
+		update.Attempts++
+		update.UpdatedAt = now
+		// The attempt is saved before it's made, so an update that crashes blanche isn't attempted forever
+		p.save(event)
+		url, err := p.apply(update.Entry, event.Name, event.Tag)
+		switch {
+		case err == nil || url != "":
+			// With a URL, the update was made and only what follows it failed, like closing outdated PRs,
+			// so it isn't attempted again
+			if err != nil {
+				log.Printf("%s:%s | updated %s, but: %s", event.Name, event.Tag, url, err)
+			}
+			update.Status = store.StatusDone
+			update.Error = ""
+			update.URL = url
+			p.emit(event, *update)
+		case !gh.IsRetryable(err):
+			log.Printf("%s:%s | %s\n%+v", event.Name, event.Tag, err, update.Entry)
+			update.Status = store.StatusFailed
+			update.Error = err.Error()
+		case update.Attempts >= p.MaxAttempts:
+			log.Printf("%s:%s | giving up after %d attempts: %s\n%+v", event.Name, event.Tag, update.Attempts, err, update.Entry)
+			update.Status = store.StatusDeadLetter
+			update.Error = err.Error()
+		default:
+			delay := p.backoff(update.Attempts)
+			log.Printf("%s:%s | retrying in %s: %s\n%+v", event.Name, event.Tag, delay, err, update.Entry)
+			update.Status = store.StatusRetrying
+			update.Error = err.Error()
+			update.NextAttemptAt = now.Add(delay)
+			event.Done = false
+		}
+		p.save(event)
+	}
+	p.finish(event)
+}
+
+// apply makes the update, returning an error if it panics so the update is recorded as failed
+func (p *Processor) apply(entry config.ManifestEntry, name, tag string) (url string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s:%s | panic while updating %s/%s: %v\n%s", name, tag, entry.ConfigRepo, entry.File, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return p.update(entry, name, tag)
+}
+
+// finish saves the event, and reports its outcome if it is done
+func (p *Processor) finish(event store.Event) {
+	if event.Done && event.CallbackURL != "" && !event.CallbackSent {
+		if err := p.callback(event.CallbackURL, newCallback(event)); err != nil {
+			log.Printf("%s:%s | failed to send callback: %s", event.Name, event.Tag, err)
+		}
+		// Callbacks are only attempted once, since Docker Hub only accepts the first one
+		event.CallbackSent = true
+	}
+	p.save(event)
+}
+
+// emit sends a CloudEvent for a successful update to the EventSink
+func (p *Processor) emit(event store.Event, update store.Update) {
+	if p.EventSink == "" {
+		return
+	}
+	eventType := cloudevents.UpdateCommitted
+	if update.Entry.PullRequest {
+		eventType = cloudevents.PullRequestOpened
+	}
+	data := cloudevents.UpdateData{
+		Repository: event.Name,
+		Tag:        event.Tag,
+		ConfigRepo: update.Entry.ConfigRepo,
+		File:       update.Entry.File,
+		BaseBranch: update.Entry.BaseBranch,
+		URL:        update.URL,
+	}
+	e, err := cloudevents.New(eventType, p.EventSource, event.Name+":"+event.Tag, data)
+	if err == nil {
+		err = p.send(p.EventSink, e)
+	}
+	if err != nil {
+		log.Printf("%s:%s | failed to send %s event: %s", event.Name, event.Tag, eventType, err)
+	}
+}
+
+// backoff returns the delay before the next attempt. It doubles with every
+// attempt up to MaxDelay, and is jittered so that updates that failed at the
+// same time aren't all retried at the same time.
+func (p *Processor) backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// resolve populates the event's updates from its manifest.
+// It returns false if there is nothing to update.
+func (p *Processor) resolve(event *store.Event) bool {
+	if err := config.ValidateTag(event.Tag); err != nil {
+		return false
+	}
+	match := config.GetManifest(event.Name, event.Aliases...)
+	if match == nil {
+		log.Printf("No matching manifest for %s:%s", event.Name, event.Tag)
+		return false
+	}
+
+	event.Updates = []store.Update{}
+	for _, entry := range match.Manifests {
+		event.Updates = append(event.Updates, store.Update{Entry: entry, Status: store.StatusPending})
+	}
+	return true
+}
+
+func (p *Processor) save(event store.Event) {
+	if err := p.store.Save(event); err != nil {
+		log.Printf("%s:%s | failed to save event %s: %s", event.Name, event.Tag, event.ID, err)
+	}
+}
@@ -0,0 +1,418 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/cloudevents"
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/gh"
+	"github.com/RentTheRunway/blanche/pkg/store"
+	"github.com/google/go-github/v31/github"
+)
+
+func newStore(t *testing.T) *store.Store {
+	dir, err := ioutil.TempDir("", "blanche-queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	s, err := store.Open(filepath.Join(dir, "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func responseErr(code int) error {
+	return &github.ErrorResponse{Response: &http.Response{
+		StatusCode: code,
+		Request:    &http.Request{Method: http.MethodPatch, URL: &url.URL{Path: "/repos/o/r/git/refs/heads/master"}},
+	}}
+}
+
+func TestProcessor_Process(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+
+	var err error
+	var calls int
+	p := NewProcessor(s)
+	p.BaseDelay = 0
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		calls++
+		if err != nil {
+			return "", err
+		}
+		return "https://github.com/o/r/pull/1", nil
+	}
+
+	event, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1"})
+	job := Job{ID: event.ID, Name: event.Name, Tag: event.Tag}
+
+	err = responseErr(http.StatusBadGateway)
+	p.Process(job)
+	got, _ := s.Get(event.ID)
+	if got.Done {
+		t.Error("expected event not to be done after a retryable error")
+	}
+	if len(got.Updates) != 1 || got.Updates[0].Status != store.StatusRetrying || got.Updates[0].Attempts != 1 {
+		t.Errorf("expected a single retrying update, got: %+v", got.Updates)
+	}
+
+	// Processing it again, like after a restart or a retry, retries the failed update
+	err = nil
+	p.Process(job)
+	got, _ = s.Get(event.ID)
+	if !got.Done {
+		t.Error("expected event to be done")
+	}
+	if got.Updates[0].Status != store.StatusDone || got.Updates[0].Error != "" || got.Updates[0].Attempts != 2 || got.Updates[0].URL == "" {
+		t.Errorf("expected a single successful update, got: %+v", got.Updates)
+	}
+
+	// Done updates aren't applied again
+	p.Process(job)
+	if calls != 2 {
+		t.Errorf("expected 2 updates, got: %d", calls)
+	}
+}
+
+func TestProcessor_ProcessFailures(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+
+	var err error
+	p := NewProcessor(s)
+	p.BaseDelay = 0
+	p.MaxAttempts = 3
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) { return "", err }
+
+	tests := []struct {
+		err      error
+		status   store.Status
+		attempts int
+	}{
+		{errors.New(gh.ErrTagPrecedesCurrentTag), store.StatusFailed, 1},
+		{responseErr(http.StatusNotFound), store.StatusFailed, 1},
+		{responseErr(http.StatusBadGateway), store.StatusDeadLetter, 3},
+	}
+
+	for _, test := range tests {
+		err = test.err
+		event, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1"})
+		job := Job{ID: event.ID, Name: event.Name, Tag: event.Tag}
+		for i := 0; i < p.MaxAttempts+1; i++ {
+			p.Process(job)
+		}
+
+		got, _ := s.Get(event.ID)
+		if !got.Done {
+			t.Errorf("%s | expected event to be done", test.err)
+		}
+		update := got.Updates[0]
+		if update.Status != test.status || update.Attempts != test.attempts || update.Error != test.err.Error() {
+			t.Errorf("%s | expected status: %s after %d attempts, got: %+v", test.err, test.status, test.attempts, update)
+		}
+	}
+}
+
+func TestProcessor_ProcessCloseOutdatedPRsFailure(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+
+	var calls int
+	p := NewProcessor(s)
+	p.BaseDelay = 0
+	// The PR was opened, but closing the PRs it supersedes failed
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		calls++
+		return "https://github.com/o/r/pull/2", responseErr(http.StatusBadGateway)
+	}
+
+	event, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1"})
+	job := Job{ID: event.ID, Name: event.Name, Tag: event.Tag}
+	p.Process(job)
+	p.Process(job)
+
+	got, _ := s.Get(event.ID)
+	if !got.Done {
+		t.Error("expected event to be done")
+	}
+	update := got.Updates[0]
+	if update.Status != store.StatusDone || update.URL != "https://github.com/o/r/pull/2" || update.Error != "" {
+		t.Errorf("expected a successful update with the PR's URL, got: %+v", update)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 update, got: %d", calls)
+	}
+}
+
+func TestProcessor_ProcessPanic(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+
+	p := NewProcessor(s)
+	event, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1"})
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		// The attempt is saved before it's made
+		if saved, _ := s.Get(event.ID); saved.Updates[0].Attempts != 1 {
+			t.Errorf("expected the attempt to be saved, got: %+v", saved.Updates)
+		}
+		panic("image isn't a map")
+	}
+
+	p.Process(Job{ID: event.ID, Name: event.Name, Tag: event.Tag})
+	got, _ := s.Get(event.ID)
+	if !got.Done {
+		t.Error("expected event to be done")
+	}
+	expected := "panic: image isn't a map"
+	if update := got.Updates[0]; update.Status != store.StatusFailed || update.Error != expected {
+		t.Errorf("expected a failed update with error: %s, got: %+v", expected, update)
+	}
+
+	// An update that was interrupted on its last attempt, like by a crash, isn't attempted again
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		t.Error("expected the interrupted update not to be attempted again")
+		return "", nil
+	}
+	got.Done = false
+	got.Updates[0].Status = store.StatusPending
+	got.Updates[0].Attempts = p.MaxAttempts
+	s.Save(got)
+	p.Process(Job{ID: event.ID, Name: event.Name, Tag: event.Tag})
+	got, _ = s.Get(event.ID)
+	if !got.Done || got.Updates[0].Status != store.StatusDeadLetter {
+		t.Errorf("expected a dead letter update, got: %+v", got.Updates)
+	}
+}
+
+func TestProcessor_ProcessPullRequestRetry(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	gh.SetClient(client)
+	defer gh.SetClient(nil)
+
+	// The first attempt pushes the commit to the PR's branch, but fails to open the PR
+	var pushed bool
+	var prsOpened int
+	repo := "/repos/o/configs"
+	mux.HandleFunc(repo+"/git/refs/heads/auto-release/master/celfring/guestbook-v1.1.0", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPatch:
+			pushed = true
+		case !pushed:
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"ref": "refs/heads/auto-release/master/celfring/guestbook-v1.1.0", "object": {"sha": "new"}}`)
+	})
+	mux.HandleFunc(repo+"/git/refs/heads/master", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/master", "object": {"sha": "base"}}`)
+	})
+	mux.HandleFunc(repo+"/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/auto-release/master/celfring/guestbook-v1.1.0", "object": {"sha": "base"}}`)
+	})
+	mux.HandleFunc(repo+"/contents/values.yaml", func(w http.ResponseWriter, r *http.Request) {
+		content := "image:\n  tag: v1.0.0\n"
+		if pushed && r.URL.Query().Get("ref") != "refs/heads/master" {
+			content = "image:\n  tag: v1.1.0\n"
+		}
+		fmt.Fprintf(w, `{"type": "file", "content": %q}`, content)
+	})
+	mux.HandleFunc(repo+"/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "tree"}`)
+	})
+	mux.HandleFunc(repo+"/commits/base", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "base", "commit": {}}`)
+	})
+	mux.HandleFunc(repo+"/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "new"}`)
+	})
+	mux.HandleFunc(repo+"/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		prsOpened++
+		if prsOpened == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"number": 1, "html_url": "https://github.com/o/configs/pull/1"}`)
+	})
+
+	s := newStore(t)
+	p := NewProcessor(s)
+	p.BaseDelay = 0
+	event, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1.1.0"})
+	event.Updates = []store.Update{{
+		Entry:  config.ManifestEntry{ConfigRepo: "o/configs", File: "values.yaml", BaseBranch: "master", PullRequest: true},
+		Status: store.StatusPending,
+	}}
+	s.Save(event)
+	job := Job{ID: event.ID, Name: event.Name, Tag: event.Tag}
+
+	p.Process(job)
+	got, _ := s.Get(event.ID)
+	if !pushed || got.Updates[0].Status != store.StatusRetrying {
+		t.Fatalf("expected the commit to be pushed and the update to be retried, got: %+v", got.Updates)
+	}
+
+	// The retry finds the tag already on the branch, and only opens the PR
+	p.Process(job)
+	got, _ = s.Get(event.ID)
+	expected := "https://github.com/o/configs/pull/1"
+	if update := got.Updates[0]; update.Status != store.StatusDone || update.URL != expected {
+		t.Errorf("expected a done update with url: %s, got: %+v", expected, update)
+	}
+	if prsOpened != 2 {
+		t.Errorf("expected 2 attempts to open the PR, got: %d", prsOpened)
+	}
+}
+
+func TestProcessor_backoff(t *testing.T) {
+	p := NewProcessor(nil)
+	p.BaseDelay = time.Second
+	p.MaxDelay = 5 * time.Second
+
+	tests := []struct {
+		attempts int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{4, 2500 * time.Millisecond, 5 * time.Second},
+		{20, 2500 * time.Millisecond, 5 * time.Second},
+	}
+	for _, test := range tests {
+		if got := p.backoff(test.attempts); got < test.min || got > test.max {
+			t.Errorf("backoff(%d) | expected between %s and %s, got: %s", test.attempts, test.min, test.max, got)
+		}
+	}
+}
+
+func TestProcessor_ProcessNothingToUpdate(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+	p := NewProcessor(s)
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		t.Errorf("unexpected update of %+v", entry)
+		return "", nil
+	}
+
+	tests := []struct{ name, tag string }{
+		{"celfring/guestbook", "latest"},
+		{"celfring/no-entry", "v1"},
+	}
+	for _, test := range tests {
+		event, _ := s.Add(store.Event{Name: test.name, Tag: test.tag})
+		p.Process(Job{ID: event.ID, Name: event.Name, Tag: event.Tag})
+		if got, _ := s.Get(event.ID); !got.Done || got.Updates != nil {
+			t.Errorf("%s:%s | expected event to be done with no updates, got: %+v", test.name, test.tag, got)
+		}
+	}
+}
+
+func TestProcessor_ProcessCallback(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+
+	var callbacks []Callback
+	p := NewProcessor(s)
+	p.BaseDelay = time.Hour
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		return "", responseErr(http.StatusBadGateway)
+	}
+	p.callback = func(callbackURL string, payload Callback) error {
+		if callbackURL != "https://registry.hub.docker.com/u/o/r/hook/1/" {
+			t.Errorf("unexpected callback url: %s", callbackURL)
+		}
+		callbacks = append(callbacks, payload)
+		return nil
+	}
+
+	event, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1", CallbackURL: "https://registry.hub.docker.com/u/o/r/hook/1/"})
+	job := Job{ID: event.ID, Name: event.Name, Tag: event.Tag}
+	p.Process(job)
+	if len(callbacks) != 0 {
+		t.Errorf("expected no callback while the update is being retried, got: %+v", callbacks)
+	}
+
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		return "https://github.com/caitlin615/argocd-demo/commit/abc", nil
+	}
+	p.BaseDelay = 0
+	got, _ := s.Get(event.ID)
+	got.Updates[0].NextAttemptAt = time.Now()
+	s.Save(got)
+	p.Process(job)
+	p.Process(job)
+
+	expected := []Callback{{
+		State:       CallbackSuccess,
+		Description: "Updated 1 of 1 manifests: caitlin615/argocd-demo/charts/guestbook/values.yaml",
+		Context:     "blanche",
+		TargetURL:   "https://github.com/caitlin615/argocd-demo/commit/abc",
+	}}
+	if !reflect.DeepEqual(callbacks, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, callbacks)
+	}
+}
+
+func TestProcessor_ProcessEvents(t *testing.T) {
+	os.Setenv("MANIFEST_PATH", "../config/manifest-test.yaml")
+	s := newStore(t)
+
+	var sent []cloudevents.Event
+	p := NewProcessor(s)
+	p.update = func(entry config.ManifestEntry, name, tag string) (string, error) {
+		return "https://github.com/caitlin615/argocd-demo/pull/1", nil
+	}
+	p.send = func(sink string, e cloudevents.Event) error {
+		sent = append(sent, e)
+		return nil
+	}
+
+	// Nothing is sent without a sink
+	guestbook, _ := s.Add(store.Event{Name: "celfring/guestbook", Tag: "v1"})
+	p.Process(Job{ID: guestbook.ID})
+	if len(sent) != 0 {
+		t.Errorf("expected no events without a sink, got: %+v", sent)
+	}
+
+	p.EventSink = "https://events.example.com"
+	guestbook, _ = s.Add(store.Event{Name: "celfring/guestbook", Tag: "v2"})
+	p.Process(Job{ID: guestbook.ID})
+	demo, _ := s.Add(store.Event{Name: "celfring/k8s-demo", Tag: "v2"})
+	p.Process(Job{ID: demo.ID})
+
+	tests := []struct {
+		eventType, subject string
+	}{
+		{cloudevents.UpdateCommitted, "celfring/guestbook:v2"},
+		{cloudevents.PullRequestOpened, "celfring/k8s-demo:v2"},
+	}
+	if len(sent) != len(tests) {
+		t.Fatalf("expected %d events, got: %+v", len(tests), sent)
+	}
+	for i, test := range tests {
+		if sent[i].Type != test.eventType || sent[i].Subject != test.subject || sent[i].Source != DefaultEventSource {
+			t.Errorf("expected: %s %s, got: %+v", test.eventType, test.subject, sent[i])
+		}
+	}
+}
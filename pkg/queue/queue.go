@@ -0,0 +1,276 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/store"
+)
+
+const (
+	DefaultWorkers = 2
+	DefaultSize    = 100
+	// RetryInterval is how often the store is checked for updates that are due to be retried
+	RetryInterval = 10 * time.Second
+)
+
+var (
+	ErrQueueFull    = errors.New("Job queue is full")
+	ErrQueueStopped = errors.New("Job queue is stopped")
+	ErrNotFound     = errors.New("Event not found")
+	ErrInFlight     = errors.New("Event is being processed")
+)
+
+// Job is a single docker image tag that needs to be applied to its manifests
+type Job struct {
+	// ID is the ID of the job's event in the store
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+	// Aliases are the image's other names, whose manifests are updated too
+	Aliases []string `json:"aliases,omitempty"`
+	// CallbackURL is where the outcome is reported, for registries that support it
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// Stats is a snapshot of the queue, used to inspect its current state
+type Stats struct {
+	Depth    int   `json:"depth"`
+	Capacity int   `json:"capacity"`
+	InFlight int64 `json:"in_flight"`
+	Workers  int   `json:"workers"`
+}
+
+// Queue is a bounded job queue processed by a fixed pool of workers
+type Queue struct {
+	jobs     chan Job
+	workers  int
+	inFlight int64
+	process  func(Job)
+	store    *store.Store
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	stopped bool
+	stop    chan struct{}
+	// queued holds the IDs of jobs that are queued or in flight,
+	// so the same event is never processed by two workers at once
+	queued map[string]bool
+}
+
+// New creates a queue. When s is not nil, every enqueued job is recorded
+// in it before it is queued, so it can be replayed after a restart.
+func New(workers, size int, s *store.Store, process func(Job)) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &Queue{
+		jobs:    make(chan Job, size),
+		workers: workers,
+		process: process,
+		store:   s,
+		stop:    make(chan struct{}),
+		queued:  map[string]bool{},
+	}
+}
+
+// Start starts the workers. Each worker will run until Stop is called
+func (q *Queue) Start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// Stop stops accepting jobs and waits for all queued jobs to finish
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.stop)
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// Enqueue adds a job to the queue without blocking.
+// ErrQueueFull is returned if there is no room left in the queue.
+func (q *Queue) Enqueue(job Job) error {
+	return q.EnqueueAll([]Job{job})
+}
+
+// EnqueueAll adds every job to the queue without blocking, like for a webhook with more than one tag.
+// Either all of them are queued, or none of them are and ErrQueueFull is returned.
+func (q *Queue) EnqueueAll(jobs []Job) error {
+	// The events are recorded without holding q.mu, so that webhooks aren't serialized on writing the store
+	var added []string
+	if q.store != nil {
+		jobs = append([]Job(nil), jobs...)
+		for i, job := range jobs {
+			if job.ID != "" {
+				continue
+			}
+			event, err := q.store.Add(store.Event{Name: job.Name, Tag: job.Tag, Aliases: job.Aliases, CallbackURL: job.CallbackURL})
+			if err != nil {
+				q.deleteAll(added)
+				return err
+			}
+			jobs[i].ID = event.ID
+			added = append(added, event.ID)
+		}
+	}
+
+	err := q.push(jobs)
+	if err != nil {
+		// The webhook is rejected, so there's nothing to replay
+		q.deleteAll(added)
+	}
+	return err
+}
+
+func (q *Queue) deleteAll(ids []string) {
+	for _, id := range ids {
+		q.store.Delete(id)
+	}
+}
+
+// push adds the jobs that aren't already queued to the queue, if there's room for all of them
+func (q *Queue) push(jobs []Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		return ErrQueueStopped
+	}
+
+	var pending []Job
+	for _, job := range jobs {
+		if job.ID == "" || !q.queued[job.ID] {
+			pending = append(pending, job)
+		}
+	}
+	// Jobs are only sent while holding q.mu, so the room can't be taken by another push
+	if len(pending) > cap(q.jobs)-len(q.jobs) {
+		return ErrQueueFull
+	}
+	for _, job := range pending {
+		q.jobs <- job
+		if job.ID != "" {
+			q.queued[job.ID] = true
+		}
+	}
+	return nil
+}
+
+// Replay enqueues every event in the store that has updates due.
+// It returns the number of jobs that were enqueued. Anything that
+// doesn't fit in the queue is picked up by RetryEvery later on.
+func (q *Queue) Replay() int {
+	if q.store == nil {
+		return 0
+	}
+	n := 0
+	now := time.Now()
+	for _, e := range q.store.Unfinished() {
+		if !e.Due(now) {
+			continue
+		}
+		if err := q.Enqueue(Job{ID: e.ID, Name: e.Name, Tag: e.Tag, Aliases: e.Aliases}); err != nil {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// RetryEvery replays the store on the given interval, until Stop is called
+func (q *Queue) RetryEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-q.stop:
+				return
+			case <-ticker.C:
+				q.Replay()
+			}
+		}
+	}()
+}
+
+// Redrive resets the event's dead letter updates so they are attempted again
+func (q *Queue) Redrive(id string) (store.Event, error) {
+	if q.store == nil {
+		return store.Event{}, ErrNotFound
+	}
+	q.mu.Lock()
+	// The event can't be changed while a worker has it
+	if q.queued[id] {
+		q.mu.Unlock()
+		return store.Event{}, ErrInFlight
+	}
+	event, ok := q.store.Get(id)
+	if !ok {
+		q.mu.Unlock()
+		return store.Event{}, ErrNotFound
+	}
+	for i, u := range event.Updates {
+		if u.Status == store.StatusDeadLetter {
+			event.Updates[i].Status = store.StatusPending
+			event.Updates[i].Attempts = 0
+			event.Updates[i].NextAttemptAt = time.Time{}
+			event.Done = false
+		}
+	}
+	err := q.store.Save(event)
+	q.mu.Unlock()
+	if err != nil {
+		return event, err
+	}
+	log.Printf("%s:%s | redriving event %s", event.Name, event.Tag, event.ID)
+	// If the queue is full, the event will be replayed later on
+	if err := q.Enqueue(Job{ID: event.ID, Name: event.Name, Tag: event.Tag, Aliases: event.Aliases}); err != nil && err != ErrQueueFull {
+		return event, err
+	}
+	return event, nil
+}
+
+func (q *Queue) Stats() Stats {
+	return Stats{
+		Depth:    len(q.jobs),
+		Capacity: cap(q.jobs),
+		InFlight: atomic.LoadInt64(&q.inFlight),
+		Workers:  q.workers,
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		atomic.AddInt64(&q.inFlight, 1)
+		q.run(job)
+		atomic.AddInt64(&q.inFlight, -1)
+
+		q.mu.Lock()
+		delete(q.queued, job.ID)
+		q.mu.Unlock()
+	}
+}
+
+// run processes the job, recovering from a panic so it doesn't take down every other job with it
+func (q *Queue) run(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s:%s | panic while processing event %s: %v\n%s", job.Name, job.Tag, job.ID, r, debug.Stack())
+		}
+	}()
+	q.process(job)
+}
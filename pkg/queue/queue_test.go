@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/store"
+)
+
+func TestQueue_Enqueue(t *testing.T) {
+	q := New(1, 1, nil, func(Job) {})
+	if err := q.Enqueue(Job{Name: "o/r", Tag: "v1"}); err != nil {
+		t.Error(err)
+	}
+	// The worker hasn't been started, so the queue has no room for a second job
+	if err := q.Enqueue(Job{Name: "o/r", Tag: "v2"}); err != ErrQueueFull {
+		t.Errorf("expected error: %s, got: %v", ErrQueueFull, err)
+	}
+
+	expected := Stats{Depth: 1, Capacity: 1, InFlight: 0, Workers: 1}
+	if s := q.Stats(); s != expected {
+		t.Errorf("expected: %+v, got: %+v", expected, s)
+	}
+}
+
+func TestQueue_EnqueueAll(t *testing.T) {
+	s := newStore(t)
+	q := New(1, 2, s, func(Job) {})
+	if err := q.EnqueueAll([]Job{{Name: "o/r", Tag: "v1"}}); err != nil {
+		t.Error(err)
+	}
+	// There's only room for one of them, so neither is queued or recorded
+	if err := q.EnqueueAll([]Job{{Name: "o/r", Tag: "v2"}, {Name: "o/r", Tag: "v2.0"}}); err != ErrQueueFull {
+		t.Errorf("expected error: %s, got: %v", ErrQueueFull, err)
+	}
+	if stats := q.Stats(); stats.Depth != 1 {
+		t.Errorf("expected a single queued job, got: %+v", stats)
+	}
+	if events := s.Unfinished(); len(events) != 1 {
+		t.Errorf("expected a single recorded event, got: %+v", events)
+	}
+}
+
+func TestQueue_Process(t *testing.T) {
+	var mu sync.Mutex
+	var got []Job
+	q := New(3, 10, nil, func(job Job) {
+		mu.Lock()
+		got = append(got, job)
+		mu.Unlock()
+	})
+	q.Start()
+
+	for _, tag := range []string{"v1", "v2", "v3", "v4"} {
+		if err := q.Enqueue(Job{Name: "o/r", Tag: tag}); err != nil {
+			t.Error(err)
+		}
+	}
+	q.Stop()
+
+	if len(got) != 4 {
+		t.Errorf("expected 4 processed jobs, got: %d", len(got))
+	}
+	if s := q.Stats(); s.Depth != 0 || s.InFlight != 0 {
+		t.Errorf("expected empty queue, got: %+v", s)
+	}
+}
+
+func TestQueue_ProcessPanic(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	q := New(1, 10, nil, func(job Job) {
+		if job.Tag == "v1" {
+			panic("image isn't a map")
+		}
+		mu.Lock()
+		got = append(got, job.Tag)
+		mu.Unlock()
+	})
+	q.Start()
+
+	for _, tag := range []string{"v1", "v2"} {
+		if err := q.Enqueue(Job{Name: "o/r", Tag: tag}); err != nil {
+			t.Error(err)
+		}
+	}
+	q.Stop()
+
+	// The worker keeps going after a job panics
+	if !reflect.DeepEqual(got, []string{"v2"}) {
+		t.Errorf("expected: [v2], got: %v", got)
+	}
+	if s := q.Stats(); s.InFlight != 0 {
+		t.Errorf("expected nothing in flight, got: %+v", s)
+	}
+}
+
+func TestNew(t *testing.T) {
+	q := New(0, -1, nil, func(Job) {})
+	expected := Stats{Depth: 0, Capacity: 0, InFlight: 0, Workers: 1}
+	if s := q.Stats(); s != expected {
+		t.Errorf("expected: %+v, got: %+v", expected, s)
+	}
+}
+
+func TestQueue_Replay(t *testing.T) {
+	s := newStore(t)
+	q := New(1, 1, s, func(Job) {})
+	if err := q.Enqueue(Job{Name: "o/r", Tag: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	// Rejected jobs shouldn't be replayed
+	if err := q.Enqueue(Job{Name: "o/r", Tag: "v2"}); err != ErrQueueFull {
+		t.Errorf("expected error: %s, got: %v", ErrQueueFull, err)
+	}
+	unfinished := s.Unfinished()
+	if len(unfinished) != 1 {
+		t.Fatalf("expected 1 unfinished event, got: %d", len(unfinished))
+	}
+
+	// Simulate a restart before the job was processed
+	var got []Job
+	restarted := New(1, 1, s, func(job Job) { got = append(got, job) })
+	if n := restarted.Replay(); n != 1 {
+		t.Errorf("expected 1 replayed job, got: %d", n)
+	}
+	restarted.Start()
+	restarted.Stop()
+
+	expected := []Job{{ID: unfinished[0].ID, Name: "o/r", Tag: "v1"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+}
+
+func TestQueue_Redrive(t *testing.T) {
+	s := newStore(t)
+	q := New(1, 1, s, func(Job) {})
+
+	event, _ := s.Add(store.Event{Name: "o/r", Tag: "v1"})
+	event.Done = true
+	event.Updates = []store.Update{
+		{Status: store.StatusDone, Attempts: 1},
+		{Status: store.StatusDeadLetter, Attempts: 5, NextAttemptAt: time.Now()},
+	}
+	s.Save(event)
+
+	if _, err := q.Redrive("missing"); err != ErrNotFound {
+		t.Errorf("expected error: %s, got: %v", ErrNotFound, err)
+	}
+
+	got, err := q.Redrive(event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Done {
+		t.Error("expected redriven event not to be done")
+	}
+	expected := []store.Update{
+		{Status: store.StatusDone, Attempts: 1},
+		{Status: store.StatusPending},
+	}
+	if !reflect.DeepEqual(got.Updates, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got.Updates)
+	}
+	if stats := q.Stats(); stats.Depth != 1 {
+		t.Errorf("expected the event to be queued, got: %+v", stats)
+	}
+
+	// The event can't be redriven again until it has been processed
+	if _, err := q.Redrive(event.ID); err != ErrInFlight {
+		t.Errorf("expected error: %s, got: %v", ErrInFlight, err)
+	}
+}
+
+func TestQueue_EnqueueDuplicate(t *testing.T) {
+	q := New(1, 2, nil, func(Job) {})
+	q.Enqueue(Job{ID: "1", Name: "o/r", Tag: "v1"})
+	q.Enqueue(Job{ID: "1", Name: "o/r", Tag: "v1"})
+	if stats := q.Stats(); stats.Depth != 1 {
+		t.Errorf("expected a single queued job, got: %+v", stats)
+	}
+
+	q.Stop()
+	if err := q.Enqueue(Job{ID: "2", Name: "o/r", Tag: "v2"}); err != ErrQueueStopped {
+		t.Errorf("expected error: %s, got: %v", ErrQueueStopped, err)
+	}
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"golang.org/x/mod/semver"
+)
+
+// DefaultRetention is how long done events are kept for
+const DefaultRetention = 7 * 24 * time.Hour
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusDone    Status = "done"
+	// StatusRetrying is an update that failed with a retryable error
+	StatusRetrying Status = "retrying"
+	// StatusFailed is an update that failed with an error that retrying won't fix
+	StatusFailed Status = "failed"
+	// StatusDeadLetter is an update that ran out of retries
+	StatusDeadLetter Status = "dead_letter"
+)
+
+// Event is a webhook that was received for a new docker image tag,
+// along with the outcome of updating each of its manifests
+type Event struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Tag        string    `json:"tag"`
+	ReceivedAt time.Time `json:"received_at"`
+	// Aliases are the image's other names, whose manifests are updated too
+	Aliases []string `json:"aliases,omitempty"`
+	// CallbackURL is where the outcome is reported once the event is done
+	CallbackURL  string `json:"callback_url,omitempty"`
+	CallbackSent bool   `json:"callback_sent,omitempty"`
+	// Done is true once none of the updates have anything left to do
+	Done bool `json:"done"`
+	// Updates is nil until the event's manifests have been resolved
+	Updates []Update `json:"updates"`
+}
+
+// Update is the outcome of updating a single ManifestEntry for an Event
+type Update struct {
+	Entry  config.ManifestEntry `json:"entry"`
+	Status Status               `json:"status"`
+	Error  string               `json:"error,omitempty"`
+	// URL is the pull request or commit that was created by the update
+	URL           string    `json:"url,omitempty"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Due returns true if the update should be attempted at the given time
+func (u Update) Due(now time.Time) bool {
+	switch u.Status {
+	case StatusPending:
+		return true
+	case StatusRetrying:
+		return !u.NextAttemptAt.After(now)
+	}
+	return false
+}
+
+// Due returns true if any of the event's updates should be attempted at the given time
+func (e Event) Due(now time.Time) bool {
+	if e.Done {
+		return false
+	}
+	if e.Updates == nil {
+		return true
+	}
+	for _, u := range e.Updates {
+		if u.Due(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetters returns the updates of the event that ran out of retries
+func (e Event) DeadLetters() []Update {
+	var updates []Update
+	for _, u := range e.Updates {
+		if u.Status == StatusDeadLetter {
+			updates = append(updates, u)
+		}
+	}
+	return updates
+}
+
+// Store keeps events in a local file so that they survive restarts.
+// The whole file is rewritten on every change, which is fine for the
+// volume of webhooks blanche receives since done events are pruned.
+type Store struct {
+	// Retention is how long done events are kept for. Dead letters, and the
+	// event with the newest tag of each docker image, are kept regardless.
+	Retention time.Duration
+
+	path   string
+	mu     sync.Mutex
+	events map[string]Event
+}
+
+// Open loads the store from path, creating it if it doesn't exist
+func Open(path string) (*Store, error) {
+	s := &Store{Retention: DefaultRetention, path: path, events: map[string]Event{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, s.write()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		s.events[e.ID] = e
+	}
+	return s, nil
+}
+
+// Add records a newly received event, assigning it an ID
+func (s *Store) Add(e Event) (Event, error) {
+	id, err := newID()
+	if err != nil {
+		return Event{}, err
+	}
+	e.ID = id
+	e.ReceivedAt = time.Now().UTC()
+	return e, s.Save(e)
+}
+
+func (s *Store) Get(id string) (Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	return e, ok
+}
+
+// Save creates or replaces the event
+func (s *Store) Save(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[e.ID] = e
+	if e.Done {
+		s.prune(time.Now())
+	}
+	return s.write()
+}
+
+// prune removes done events older than Retention, except for dead letters and
+// the event with the newest tag of each docker image, which Tags needs.
+// The caller must hold s.mu.
+func (s *Store) prune(now time.Time) {
+	newest := map[string]Event{}
+	for _, e := range s.events {
+		if n, ok := newest[e.Name]; !ok || semver.Compare(e.Tag, n.Tag) > 0 {
+			newest[e.Name] = e
+		}
+	}
+	for id, e := range s.events {
+		if e.Done && len(e.DeadLetters()) == 0 && now.Sub(e.ReceivedAt) > s.Retention && newest[e.Name].ID != id {
+			delete(s.events, id)
+		}
+	}
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, id)
+	return s.write()
+}
+
+// Unfinished returns all events that are not done, oldest first
+func (s *Store) Unfinished() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []Event
+	for _, e := range s.events {
+		if !e.Done {
+			events = append(events, e)
+		}
+	}
+	sortByReceivedAt(events)
+	return events
+}
+
+// DeadLetters returns all events with updates that ran out of retries, oldest first
+func (s *Store) DeadLetters() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []Event
+	for _, e := range s.events {
+		if len(e.DeadLetters()) > 0 {
+			events = append(events, e)
+		}
+	}
+	sortByReceivedAt(events)
+	return events
+}
+
+// Tags returns every tag that was received for the docker image, without duplicates
+func (s *Store) Tags(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var tags []string
+	for _, e := range s.events {
+		if e.Name == name && !seen[e.Tag] {
+			seen[e.Tag] = true
+			tags = append(tags, e.Tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// write replaces the file atomically so a crash can't leave it half-written.
+// The caller must hold s.mu.
+func (s *Store) write() error {
+	events := make([]Event, 0, len(s.events))
+	for _, e := range s.events {
+		events = append(events, e)
+	}
+	sortByReceivedAt(events)
+
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func sortByReceivedAt(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].ReceivedAt.Equal(events[j].ReceivedAt) {
+			return events[i].ID < events[j].ID
+		}
+		return events[i].ReceivedAt.Before(events[j].ReceivedAt)
+	})
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+)
+
+func tempStorePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "blanche-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "store.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestOpen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected store file to be created: %s", err)
+	}
+
+	e, err := s.Add(Event{Name: "o/r", Tag: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Updates = []Update{{Entry: config.ManifestEntry{ConfigRepo: "o/configs", File: "values.yaml"}, Status: StatusDone}}
+	if err := s.Save(e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening the store should load everything that was saved
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reopened.Get(e.ID)
+	if !ok {
+		t.Fatalf("expected event %s to exist after reopening", e.ID)
+	}
+	if !got.ReceivedAt.Equal(e.ReceivedAt) {
+		t.Errorf("expected received_at: %s, got: %s", e.ReceivedAt, got.ReceivedAt)
+	}
+	got.ReceivedAt = e.ReceivedAt
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("expected: %+v, got: %+v", e, got)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("should have returned an error, but got nil")
+	}
+}
+
+func TestStore_Unfinished(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.Add(Event{Name: "o/r", Tag: "v1"})
+	done, _ := s.Add(Event{Name: "o/r", Tag: "v2"})
+	done.Done = true
+	s.Save(done)
+	last, _ := s.Add(Event{Name: "o/r", Tag: "v3"})
+	deleted, _ := s.Add(Event{Name: "o/r", Tag: "v4"})
+	s.Delete(deleted.ID)
+
+	var got []string
+	for _, e := range s.Unfinished() {
+		got = append(got, e.ID)
+	}
+	expected := []string{first.ID, last.ID}
+	sort.Strings(got)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestStore_Tags(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(Event{Name: "o/r", Tag: "v2"})
+	s.Add(Event{Name: "o/r", Tag: "v1"})
+	s.Add(Event{Name: "o/r", Tag: "v2"})
+	s.Add(Event{Name: "o/other", Tag: "v3"})
+
+	expected := []string{"v1", "v2"}
+	if got := s.Tags("o/r"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	if got := s.Tags("o/missing"); got != nil {
+		t.Errorf("expected no tags, got: %v", got)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * s.Retention)
+	add := func(tag string, receivedAt time.Time, done bool, status Status) Event {
+		e, _ := s.Add(Event{Name: "o/r", Tag: tag})
+		e.ReceivedAt = receivedAt
+		e.Done = done
+		e.Updates = []Update{{Status: status}}
+		s.Save(e)
+		return e
+	}
+	add("v1.0.0", old, true, StatusDone)
+	deadLetter := add("v1.1.0", old, true, StatusDeadLetter)
+	unfinished := add("v1.0.1", old, false, StatusRetrying)
+	newest := add("v1.2.0", old, true, StatusDone)
+	recent := add("v1.1.1", time.Now(), true, StatusDone)
+
+	var got []string
+	for _, e := range s.events {
+		got = append(got, e.ID)
+	}
+	expected := []string{deadLetter.ID, unfinished.ID, newest.ID, recent.ID}
+	sort.Strings(got)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+
+	// The newest tag is still known once older events are pruned
+	if tags := s.Tags("o/r"); tags[len(tags)-1] != "v1.2.0" {
+		t.Errorf("expected the newest tag to be v1.2.0, got: %v", tags)
+	}
+}
+
+func TestEvent_Due(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		event    Event
+		expected bool
+	}{
+		{Event{}, true},
+		{Event{Done: true}, false},
+		{Event{Updates: []Update{}}, false},
+		{Event{Updates: []Update{{Status: StatusDone}, {Status: StatusPending}}}, true},
+		{Event{Updates: []Update{{Status: StatusRetrying, NextAttemptAt: now}}}, true},
+		{Event{Updates: []Update{{Status: StatusRetrying, NextAttemptAt: now.Add(time.Minute)}}}, false},
+		{Event{Updates: []Update{{Status: StatusFailed}, {Status: StatusDeadLetter}}}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.event.Due(now); got != test.expected {
+			t.Errorf("%+v | expected: %t, got: %t", test.event, test.expected, got)
+		}
+	}
+}
+
+func TestStore_DeadLetters(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, _ := s.Add(Event{Name: "o/r", Tag: "v1"})
+	dead.Updates = []Update{{Status: StatusDone}, {Status: StatusDeadLetter}}
+	s.Save(dead)
+	failed, _ := s.Add(Event{Name: "o/r", Tag: "v2"})
+	failed.Updates = []Update{{Status: StatusFailed}}
+	s.Save(failed)
+
+	got := s.DeadLetters()
+	if len(got) != 1 || got[0].ID != dead.ID {
+		t.Errorf("expected only event %s, got: %+v", dead.ID, got)
+	}
+	if updates := got[0].DeadLetters(); len(updates) != 1 {
+		t.Errorf("expected 1 dead letter update, got: %+v", updates)
+	}
+}
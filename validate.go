@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/RentTheRunway/blanche/pkg/config"
+	"github.com/RentTheRunway/blanche/pkg/gh"
+)
+
+// validate runs `blanche validate [-github] [path ...]`, and returns the exit code. Each path
+// is validated like MANIFEST_PATH, which is validated when no paths are given, from MANIFEST_REPO if it's set.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	checkGitHub := flags.Bool("github", false, "check that each config repo, base branch and file exist in GitHub, using GITHUB_ACCESS_TOKEN")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: blanche validate [-github] [manifest.yaml|directory|glob ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+	repo, branch := "", ""
+	if len(paths) == 0 {
+		path := os.Getenv("MANIFEST_PATH")
+		if path == "" {
+			path = "manifest.yaml"
+		}
+		paths = []string{path}
+		// Like the server, the manifest is read from GitHub when MANIFEST_REPO is set
+		repo, branch = config.ManifestRepo()
+	}
+	if *checkGitHub || repo != "" {
+		gh.CreateGithubClient(os.Getenv("GITHUB_ACCESS_TOKEN"))
+	}
+
+	code := 0
+	for _, path := range paths {
+		var err error
+		if repo != "" {
+			err = config.ValidateGitManifest(repo, branch, path, *checkGitHub)
+			path = fmt.Sprintf("%s@%s:%s", repo, branch, path)
+		} else {
+			err = config.ValidateManifest(path, *checkGitHub)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s is valid\n", path)
+	}
+	return code
+}